@@ -3,9 +3,9 @@ module github.com/lunfardo314/verkle
 go 1.16
 
 require (
-	github.com/stretchr/testify v1.3.0 // indirect
-	go.dedis.ch/kyber/v3 v3.0.13 // indirect
-	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5 // indirect
+	github.com/stretchr/testify v1.3.0
+	go.dedis.ch/kyber/v3 v3.0.13
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
 	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b // indirect
-	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
 )
@@ -0,0 +1,109 @@
+package ipa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestParams(t *testing.T) {
+	suite := bn256.NewSuite()
+	p, err := NewParams(suite, 257)
+	require.NoError(t, err)
+	require.EqualValues(t, 512, p.N)
+	p1, err := ParamsFromBytes(suite, p.Bytes())
+	require.NoError(t, err)
+	require.EqualValues(t, 257, p1.D)
+	for i := range p.G {
+		require.True(t, p.G[i].Equal(p1.G[i]))
+	}
+	require.True(t, p.Q.Equal(p1.Q))
+
+	_, err = NewParams(suite, 0)
+	require.Error(t, err)
+	_, err = ParamsFromBytes(suite, p.Bytes()[1:])
+	require.Error(t, err)
+}
+
+func TestCommit(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	p, err := NewParams(suite, 257)
+	require.NoError(t, err)
+
+	vect := make([]kyber.Scalar, 257)
+	vect[3] = suite.G1().Scalar().Pick(rnd)
+	vect[256] = suite.G1().Scalar().Pick(rnd)
+	c := p.Commit(vect)
+	delta := suite.G1().Scalar().Pick(rnd)
+	c = p.UpdateCommitment(c, 100, delta)
+	vect[100] = delta
+	require.True(t, c.Equal(p.Commit(vect)))
+	require.True(t, p.Commit(make([]kyber.Scalar, 257)).Equal(suite.G1().Point().Null()))
+}
+
+func TestProve(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	for _, d := range []uint16{1, 2, 5, 16, 257} {
+		p, err := NewParams(suite, d)
+		require.NoError(t, err)
+		vect := make([]kyber.Scalar, d)
+		for i := range vect {
+			if i%3 != 1 {
+				vect[i] = suite.G1().Scalar().Pick(rnd)
+			}
+		}
+		c := p.Commit(vect)
+		for _, i := range []int{0, int(d) / 2, int(d) - 1} {
+			proof := p.Prove(vect, i)
+			v := suite.G1().Scalar().Zero()
+			if vect[i] != nil {
+				v = vect[i]
+			}
+			require.True(t, p.Verify(c, proof, v, i))
+			require.False(t, p.Verify(c, proof, suite.G1().Scalar().Pick(rnd), i))
+			if d > 1 {
+				require.False(t, p.Verify(c, proof, v, (i+1)%int(d)))
+			}
+			require.False(t, p.Verify(c.Clone().Add(c, p.G[0]), proof, v, i))
+			require.False(t, p.Verify(c, proof, v, int(d)))
+		}
+	}
+}
+
+func TestProofBytes(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	p, err := NewParams(suite, 257)
+	require.NoError(t, err)
+	vect := make([]kyber.Scalar, 257)
+	vect[7] = suite.G1().Scalar().Pick(rnd)
+	vect[256] = suite.G1().Scalar().Pick(rnd)
+	c := p.Commit(vect)
+
+	start := time.Now()
+	proof := p.Prove(vect, 7)
+	t.Logf("prove: %v", time.Since(start))
+	start = time.Now()
+	require.True(t, p.Verify(c, proof, vect[7], 7))
+	t.Logf("verify: %v", time.Since(start))
+
+	data, err := proof.MarshalBinary()
+	require.NoError(t, err)
+	t.Logf("proof size: %d bytes", len(data))
+	proof1, err := ProofFromBytes(suite, data)
+	require.NoError(t, err)
+	require.True(t, p.Verify(c, proof1, vect[7], 7))
+
+	proof1.L[3], proof1.R[3] = proof1.R[3], proof1.L[3]
+	require.False(t, p.Verify(c, proof1, vect[7], 7))
+	proof1.L = proof1.L[1:]
+	require.False(t, p.Verify(c, proof1, vect[7], 7))
+	_, err = ProofFromBytes(suite, data[:len(data)-1])
+	require.Error(t, err)
+}
@@ -0,0 +1,130 @@
+// Package ipa implements Pedersen vector commitments with openings proved by the inner product argument (IPA).
+// It has the same commit/prove/verify shape as kzg.TrustedSetup, however it does not need the trusted setup:
+// all generators are hashed to G1 of the bn256 curve, so nobody knows discrete logarithms between them.
+// The price is the size of the proof, which is logarithmic in the size of the vector, and linear time of verification.
+// Commitment to the vector a of size D is C = sum<i>(a<i>*G<i>).
+// The opening of a<i> is the proof of the inner product <a, e<i>> = a<i>, where e<i> is the unit vector
+package ipa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+
+	"github.com/lunfardo314/verkle/kzg"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"golang.org/x/xerrors"
+)
+
+// Params are public parameters of commitments to vectors of size D.
+// Only D is persistent, generators are derived from it deterministically
+type Params struct {
+	Suite *bn256.Suite
+	D     uint16
+	N     int           // non-persistent. D rounded up to the power of 2, the size of vectors in the argument
+	G     []kyber.Point // non-persistent. N generators of vectors
+	Q     kyber.Point   // non-persistent. Generator of the inner product
+}
+
+// The serialized parameters:
+// - magic "IPAP"
+// - version (byte) = 1
+// - suite ID: length (byte) followed by the name of the suite, "bn256"
+// - D (uint16)
+
+const (
+	formatVersion = byte(1)
+	suiteID       = "bn256"
+)
+
+var formatMagic = []byte("IPAP")
+
+var errWrongFormat = xerrors.New("wrong format of IPA parameters")
+
+type hashablePoint interface {
+	Hash(data []byte) kyber.Point
+}
+
+// NewParams creates parameters of commitments to vectors of size d
+func NewParams(suite *bn256.Suite, d uint16) (*Params, error) {
+	if d == 0 {
+		return nil, xerrors.New("size of vectors must be positive")
+	}
+	ret := &Params{
+		Suite: suite,
+		D:     d,
+		N:     1,
+	}
+	for ret.N < int(d) {
+		ret.N *= 2
+	}
+	ret.G = make([]kyber.Point, ret.N)
+	var buf [2]byte
+	for i := range ret.G {
+		binary.LittleEndian.PutUint16(buf[:], uint16(i))
+		ret.G[i] = hashToPoint(suite, "verkle ipa generator", buf[:])
+	}
+	ret.Q = hashToPoint(suite, "verkle ipa inner product generator")
+	return ret, nil
+}
+
+// ParamsFromBytes unmarshals parameters
+func ParamsFromBytes(suite *bn256.Suite, data []byte) (*Params, error) {
+	if !bytes.HasPrefix(data, formatMagic) {
+		return nil, errWrongFormat
+	}
+	data = data[len(formatMagic):]
+	if len(data) != 2+len(suiteID)+2 || data[0] != formatVersion {
+		return nil, errWrongFormat
+	}
+	if int(data[1]) != len(suiteID) || string(data[2:2+len(suiteID)]) != suiteID {
+		return nil, xerrors.Errorf("unsupported suite: %w", errWrongFormat)
+	}
+	return NewParams(suite, binary.LittleEndian.Uint16(data[2+len(suiteID):]))
+}
+
+// ParamsFromFile reads parameters from file
+func ParamsFromFile(suite *bn256.Suite, fname string) (*Params, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return ParamsFromBytes(suite, data)
+}
+
+// Bytes marshals parameters
+func (p *Params) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(formatMagic)
+	buf.WriteByte(formatVersion)
+	buf.WriteByte(byte(len(suiteID)))
+	buf.WriteString(suiteID)
+	var tmp2 [2]byte
+	binary.LittleEndian.PutUint16(tmp2[:], p.D)
+	buf.Write(tmp2[:])
+	return buf.Bytes()
+}
+
+// Commit returns commitment sum<i>(vect<i>*G<i>) to the vector of size at most D. nil elements are zeros
+func (p *Params) Commit(vect []kyber.Scalar) kyber.Point {
+	if len(vect) > int(p.D) {
+		panic("vector is too long")
+	}
+	return kzg.MultiScalarMul(p.Suite.G1(), p.G[:len(vect)], vect)
+}
+
+// UpdateCommitment returns commitment to the vector after vect<j> has been changed by delta
+func (p *Params) UpdateCommitment(c kyber.Point, j int, delta kyber.Scalar) kyber.Point {
+	ret := p.Suite.G1().Point().Mul(delta, p.G[j])
+	return ret.Add(ret, c)
+}
+
+func hashToPoint(suite *bn256.Suite, label string, data ...[]byte) kyber.Point {
+	var buf bytes.Buffer
+	buf.WriteString(label)
+	for _, d := range data {
+		buf.Write(d)
+	}
+	return suite.G1().Point().(hashablePoint).Hash(buf.Bytes())
+}
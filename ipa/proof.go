@@ -0,0 +1,232 @@
+package ipa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/lunfardo314/verkle/kzg"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// The inner product argument proves <a, b> = v for the vector a committed with C = <a, G> and the public vector b.
+// Vectors are padded with zeros to size N. The challenge w makes the generator of the inner product Q' = w*Q and
+// C' = C + v*Q' = <a, G> + <a, b>*Q'. In each of log2(N) rounds vectors are split into halves and folded:
+//   L = <aR, GL> + <aR, bL>*Q', R = <aL, GR> + <aL, bR>*Q'
+//   a' = aL + x*aR, b' = bL + x^-1*bR, G' = GL + x^-1*GR, where x is the challenge of the round
+// so that C' + x*L + x^-1*R = <a', G'> + <a', b'>*Q'. The proof consists of all L, R and the last a of size 1.
+// The verifier folds G and b itself: G0 = sum<k>(s<k>*G<k>) and b0 = sum<k>(s<k>*b<k>), where s<k> is the product
+// of x^-1 of all rounds where k falls into the right half, and checks C' + sum(x*L + x^-1*R) == a0*(G0 + b0*Q').
+// Challenges are calculated with the Fiat-Shamir heuristic from the transcript of the parameters, C, the index, v and
+// all previous L and R
+
+// Proof is the proof of the element of the committed vector
+type Proof struct {
+	L []kyber.Point
+	R []kyber.Point
+	A kyber.Scalar
+}
+
+// Prove returns proof of vect<i>
+func (p *Params) Prove(vect []kyber.Scalar, i int) *Proof {
+	if i < 0 || i >= int(p.D) || len(vect) > int(p.D) {
+		panic("wrong index or size of the vector")
+	}
+	// nil elements are zeros. They are skipped in multi-scalar multiplications, so sparse vectors are proved faster
+	a := make([]kyber.Scalar, p.N)
+	for k, v := range vect {
+		if v != nil {
+			a[k] = v.Clone()
+		}
+	}
+	b := make([]kyber.Scalar, p.N)
+	b[i] = p.Suite.G1().Scalar().One()
+	g := make([]kyber.Point, p.N)
+	copy(g, p.G)
+
+	v := p.Suite.G1().Scalar().Zero()
+	if a[i] != nil {
+		v.Set(a[i])
+	}
+	tr := p.newTranscript(p.Commit(vect), i, v)
+	q := p.Suite.G1().Point().Mul(tr.challenge(), p.Q)
+	ret := &Proof{}
+	for n := p.N; n > 1; n /= 2 {
+		h := n / 2
+		l := kzg.MultiScalarMul(p.Suite.G1(), g[:h], a[h:n])
+		l.Add(l, p.Suite.G1().Point().Mul(p.innerProduct(a[h:n], b[:h]), q))
+		r := kzg.MultiScalarMul(p.Suite.G1(), g[h:n], a[:h])
+		r.Add(r, p.Suite.G1().Point().Mul(p.innerProduct(a[:h], b[h:n]), q))
+		ret.L = append(ret.L, l)
+		ret.R = append(ret.R, r)
+
+		x := tr.challenge(l, r)
+		xInv := p.Suite.G1().Scalar().Inv(x)
+		for k := 0; k < h; k++ {
+			a[k] = p.fold(a[k], a[h+k], x)
+			b[k] = p.fold(b[k], b[h+k], xInv)
+			e := p.Suite.G1().Point().Mul(xInv, g[h+k])
+			g[k] = e.Add(e, g[k])
+		}
+	}
+	ret.A = p.Suite.G1().Scalar().Zero()
+	if a[0] != nil {
+		ret.A.Set(a[0])
+	}
+	return ret
+}
+
+// Verify verifies proof that the vector committed with c has value v at index i
+func (p *Params) Verify(c kyber.Point, proof *Proof, v kyber.Scalar, i int) bool {
+	if i < 0 || i >= int(p.D) || proof == nil || proof.A == nil {
+		return false
+	}
+	rounds := 0
+	for n := p.N; n > 1; n /= 2 {
+		rounds++
+	}
+	if len(proof.L) != rounds || len(proof.R) != rounds {
+		return false
+	}
+	tr := p.newTranscript(c, i, v)
+	q := p.Suite.G1().Point().Mul(tr.challenge(), p.Q)
+	// left side C + v*Q' + sum(x*L + x^-1*R)
+	points := make([]kyber.Point, 0, 2+2*rounds)
+	scalars := make([]kyber.Scalar, 0, 2+2*rounds)
+	points = append(points, c, q)
+	scalars = append(scalars, p.Suite.G1().Scalar().One(), v)
+	s := []kyber.Scalar{p.Suite.G1().Scalar().One()}
+	for j := range proof.L {
+		x := tr.challenge(proof.L[j], proof.R[j])
+		xInv := p.Suite.G1().Scalar().Inv(x)
+		points = append(points, proof.L[j], proof.R[j])
+		scalars = append(scalars, x, xInv)
+		// the first round splits by the most significant bit of the index
+		next := make([]kyber.Scalar, 2*len(s))
+		for k := range s {
+			next[2*k] = s[k]
+			next[2*k+1] = p.Suite.G1().Scalar().Mul(s[k], xInv)
+		}
+		s = next
+	}
+	left := kzg.MultiScalarMul(p.Suite.G1(), points, scalars)
+	// right side a0*(G0 + b0*Q'), where b0 = s<i>, because b is the unit vector
+	right := kzg.MultiScalarMul(p.Suite.G1(), p.G, s)
+	right.Add(right, p.Suite.G1().Point().Mul(s[i], q))
+	right.Mul(proof.A, right)
+	return left.Equal(right)
+}
+
+// innerProduct returns <a, b>. nil elements are zeros
+func (p *Params) innerProduct(a, b []kyber.Scalar) kyber.Scalar {
+	ret := p.Suite.G1().Scalar().Zero()
+	t := p.Suite.G1().Scalar()
+	for k := range a {
+		if a[k] != nil && b[k] != nil {
+			ret.Add(ret, t.Mul(a[k], b[k]))
+		}
+	}
+	return ret
+}
+
+// fold returns left + x*right. nil is zero
+func (p *Params) fold(left, right, x kyber.Scalar) kyber.Scalar {
+	if right == nil {
+		return left
+	}
+	ret := p.Suite.G1().Scalar().Mul(x, right)
+	if left != nil {
+		ret.Add(ret, left)
+	}
+	return ret
+}
+
+// transcript is the running hash of all public data of the argument
+type transcript struct {
+	suite *bn256.Suite
+	h     hash.Hash
+}
+
+func (p *Params) newTranscript(c kyber.Point, i int, v kyber.Scalar) *transcript {
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		panic(err)
+	}
+	ret := &transcript{suite: p.Suite, h: h}
+	ret.h.Write(p.Bytes())
+	ret.write(c)
+	var tmp2 [2]byte
+	binary.LittleEndian.PutUint16(tmp2[:], uint16(i))
+	ret.h.Write(tmp2[:])
+	if _, err := v.MarshalTo(ret.h); err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+func (t *transcript) write(points ...kyber.Point) {
+	for _, pt := range points {
+		if _, err := pt.MarshalTo(t.h); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// challenge adds points to the transcript and returns the challenge derived from the whole transcript
+func (t *transcript) challenge(points ...kyber.Point) kyber.Scalar {
+	t.write(points...)
+	return t.suite.G1().Scalar().SetBytes(t.h.Sum(nil))
+}
+
+// MarshalBinary marshals the proof: the number of rounds (byte), L, R and A
+func (pr *Proof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if len(pr.L) != len(pr.R) || len(pr.L) > 255 {
+		return nil, xerrors.New("wrong proof")
+	}
+	buf.WriteByte(byte(len(pr.L)))
+	for _, points := range [][]kyber.Point{pr.L, pr.R} {
+		for _, pt := range points {
+			if _, err := pt.MarshalTo(&buf); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := pr.A.MarshalTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ProofFromBytes unmarshals the proof
+func ProofFromBytes(suite *bn256.Suite, data []byte) (*Proof, error) {
+	r := bytes.NewReader(data)
+	var rounds [1]byte
+	if _, err := io.ReadFull(r, rounds[:]); err != nil {
+		return nil, err
+	}
+	ret := &Proof{
+		L: make([]kyber.Point, rounds[0]),
+		R: make([]kyber.Point, rounds[0]),
+		A: suite.G1().Scalar(),
+	}
+	for _, points := range [][]kyber.Point{ret.L, ret.R} {
+		for k := range points {
+			points[k] = suite.G1().Point()
+			if _, err := points[k].UnmarshalFrom(r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := ret.A.UnmarshalFrom(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errWrongFormat
+	}
+	return ret, nil
+}
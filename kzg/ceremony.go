@@ -0,0 +1,282 @@
+package kzg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// Ceremony is the multi-party generation of the powers of tau.
+// It starts with the secret s = 1. Each participant mixes in own secret t by multiplying each power [s^i] by t^i,
+// so the resulting secret is the product of secrets of all participants. It remains unknown unless all participants collude.
+// Each participant publishes the contribution: the new [s]1, the public key [t]2 and the proof of knowledge of t.
+// The transcript of all contributions is verified with pairings:
+// - e([s_k]1, [1]2) == e([s_k-1]1, [t_k]2), i.e. each contribution builds on the previous one
+// - the proof of knowledge of t_k is valid, so the contribution is not copied from another participant
+// - powers are consecutive powers of the same secret and [s]1 of the last contribution is the first power
+type Ceremony struct {
+	Powers        *PowersOfTau
+	Contributions []*Contribution
+}
+
+// Contribution is the record of one participant of the ceremony
+type Contribution struct {
+	S1     kyber.Point  // [s]1 after the contribution
+	PubKey kyber.Point  // [t]2
+	R      kyber.Point  // Schnorr proof of knowledge of t: R = [k]2 for random k,
+	Z      kyber.Scalar // Z = k + c*t, where c = hash(previous [s]1, S1, PubKey, R)
+}
+
+var (
+	errWrongContribution = xerrors.New("wrong contribution secret")
+	errNoContributions   = xerrors.New("ceremony has no contributions")
+)
+
+// NewCeremony creates ceremony with n powers on G1 and on the blinding generator and n2 powers on G2.
+// Initially the secret is 1, i.e. all powers are generators
+func NewCeremony(suite *bn256.Suite, n, n2 uint16) (*Ceremony, error) {
+	if n < 2 || n2 < 2 {
+		return nil, errNotEnoughPowers
+	}
+	ret := &Ceremony{
+		Powers: &PowersOfTau{
+			Suite: suite,
+			G1:    make([]kyber.Point, n),
+			G2:    make([]kyber.Point, n2),
+			H:     make([]kyber.Point, n),
+		},
+		Contributions: make([]*Contribution, 0),
+	}
+	for i := range ret.Powers.G1 {
+		ret.Powers.G1[i] = suite.G1().Point().Base()
+		ret.Powers.H[i] = blindingGenerator.Clone()
+	}
+	for i := range ret.Powers.G2 {
+		ret.Powers.G2[i] = suite.G2().Point().Base()
+	}
+	return ret, nil
+}
+
+// CeremonyFromBytes unmarshals the ceremony
+func CeremonyFromBytes(suite *bn256.Suite, data []byte) (*Ceremony, error) {
+	ret := &Ceremony{Powers: &PowersOfTau{Suite: suite}}
+	r := bytes.NewReader(data)
+	if err := ret.read(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errWrongPowers
+	}
+	if err := ret.Powers.check(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CeremonyFromFile reads the ceremony from file
+func CeremonyFromFile(suite *bn256.Suite, fname string) (*Ceremony, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return CeremonyFromBytes(suite, data)
+}
+
+// Bytes marshals the ceremony: powers of tau followed by contributions
+func (c *Ceremony) Bytes() []byte {
+	var buf bytes.Buffer
+	if err := c.write(&buf); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Contribute mixes secret t into the powers and appends the contribution.
+// The secret must be destroyed after the call
+func (c *Ceremony) Contribute(t kyber.Scalar) error {
+	suite := c.Powers.Suite
+	if t.Equal(suite.G1().Scalar().Zero()) || t.Equal(suite.G1().Scalar().One()) {
+		return errWrongContribution
+	}
+	ti := suite.G1().Scalar().One()
+	for i := range c.Powers.G1 {
+		c.Powers.G1[i].Mul(ti, c.Powers.G1[i])
+		if i < len(c.Powers.H) {
+			c.Powers.H[i].Mul(ti, c.Powers.H[i])
+		}
+		ti.Mul(ti, t)
+	}
+	ti.One()
+	for i := range c.Powers.G2 {
+		c.Powers.G2[i].Mul(ti, c.Powers.G2[i])
+		ti.Mul(ti, t)
+	}
+	ti.Zero()
+
+	k := suite.G2().Scalar().Pick(random.New())
+	contr := &Contribution{
+		S1:     c.Powers.G1[1].Clone(),
+		PubKey: suite.G2().Point().Mul(t, nil),
+		R:      suite.G2().Point().Mul(k, nil),
+	}
+	ch := contributionChallenge(suite, c.lastS1(), contr)
+	contr.Z = suite.G2().Scalar().Mul(ch, t)
+	contr.Z.Add(contr.Z, k)
+	k.Zero()
+	c.Contributions = append(c.Contributions, contr)
+	return nil
+}
+
+// VerifyTranscript verifies the chain of contributions and that powers are the result of it
+func (c *Ceremony) VerifyTranscript() error {
+	suite := c.Powers.Suite
+	if err := c.Powers.check(); err != nil {
+		return err
+	}
+	g2 := suite.G2().Point().Base()
+	prev := suite.G1().Point().Base()
+	for i, contr := range c.Contributions {
+		if contr.PubKey.Equal(suite.G2().Point().Null()) || contr.PubKey.Equal(g2) {
+			return xerrors.Errorf("contribution %d: %w", i, errWrongContribution)
+		}
+		if !suite.Pair(contr.S1, g2).Equal(suite.Pair(prev, contr.PubKey)) {
+			return xerrors.Errorf("contribution %d does not build on the previous one", i)
+		}
+		ch := contributionChallenge(suite, prev, contr)
+		left := suite.G2().Point().Mul(contr.Z, nil)
+		right := suite.G2().Point().Mul(ch, contr.PubKey)
+		if !left.Equal(right.Add(right, contr.R)) {
+			return xerrors.Errorf("contribution %d: wrong proof of knowledge", i)
+		}
+		prev = contr.S1
+	}
+	if !prev.Equal(c.Powers.G1[1]) {
+		return xerrors.New("powers do not match the last contribution")
+	}
+	return c.Powers.verifyPowers()
+}
+
+// verifyPowers checks that G1, G2 and H are consecutive powers of the same secret s, with [s]2 = G2<1>.
+// Checks are combined with random scalars rho<i>, e.g. e(sum<i>(rho<i>*G1<i+1>), [1]2) == e(sum<i>(rho<i>*G1<i>), [s]2)
+func (pt *PowersOfTau) verifyPowers() error {
+	rnd := random.New()
+	g1 := pt.Suite.G1().Point().Base()
+	g2 := pt.Suite.G2().Point().Base()
+	randomScalars := func(n int) []kyber.Scalar {
+		ret := make([]kyber.Scalar, n)
+		for i := range ret {
+			ret[i] = pt.Suite.G1().Scalar().Pick(rnd)
+		}
+		return ret
+	}
+	check := func(points []kyber.Point, group kyber.Group) (kyber.Point, kyber.Point) {
+		rho := randomScalars(len(points) - 1)
+		return multiScalarMul(group, points[1:], rho), multiScalarMul(group, points[:len(points)-1], rho)
+	}
+	if len(pt.G1) > 1 {
+		next, cur := check(pt.G1, pt.Suite.G1())
+		if !pt.Suite.Pair(next, g2).Equal(pt.Suite.Pair(cur, pt.G2[1])) {
+			return xerrors.New("wrong powers on G1")
+		}
+	}
+	if len(pt.H) > 1 {
+		next, cur := check(pt.H, pt.Suite.G1())
+		if !pt.Suite.Pair(next, g2).Equal(pt.Suite.Pair(cur, pt.G2[1])) {
+			return xerrors.New("wrong powers on the blinding generator")
+		}
+	}
+	next, cur := check(pt.G2, pt.Suite.G2())
+	if !pt.Suite.Pair(g1, next).Equal(pt.Suite.Pair(pt.G1[1], cur)) {
+		return xerrors.New("wrong powers on G2")
+	}
+	return nil
+}
+
+// Finalize verifies the transcript and derives the trusted setup of degree d with the domain defined by omega
+// (see TrustedSetupFromPowersOfTau)
+func (c *Ceremony) Finalize(d uint16, omega kyber.Scalar) (*TrustedSetup, error) {
+	if len(c.Contributions) == 0 {
+		return nil, errNoContributions
+	}
+	if err := c.VerifyTranscript(); err != nil {
+		return nil, err
+	}
+	return TrustedSetupFromPowersOfTau(c.Powers, d, omega)
+}
+
+func (c *Ceremony) lastS1() kyber.Point {
+	if len(c.Contributions) == 0 {
+		return c.Powers.Suite.G1().Point().Base()
+	}
+	return c.Contributions[len(c.Contributions)-1].S1
+}
+
+// contributionChallenge is a Fiat-Shamir challenge c = hash(previous [s]1, S1, PubKey, R)
+func contributionChallenge(suite *bn256.Suite, prev kyber.Point, contr *Contribution) kyber.Scalar {
+	h, _ := blake2b.New256(nil)
+	for _, p := range []kyber.Point{prev, contr.S1, contr.PubKey, contr.R} {
+		if _, err := p.MarshalTo(h); err != nil {
+			panic(err)
+		}
+	}
+	return suite.G1().Scalar().SetBytes(h.Sum(nil))
+}
+
+func (c *Ceremony) write(w io.Writer) error {
+	if err := c.Powers.write(w); err != nil {
+		return err
+	}
+	var tmp2 [2]byte
+	binary.LittleEndian.PutUint16(tmp2[:], uint16(len(c.Contributions)))
+	if _, err := w.Write(tmp2[:]); err != nil {
+		return err
+	}
+	for _, contr := range c.Contributions {
+		for _, p := range []kyber.Point{contr.S1, contr.PubKey, contr.R} {
+			if _, err := p.MarshalTo(w); err != nil {
+				return err
+			}
+		}
+		if _, err := contr.Z.MarshalTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Ceremony) read(r io.Reader) error {
+	if err := c.Powers.read(r); err != nil {
+		return err
+	}
+	var tmp2 [2]byte
+	if _, err := io.ReadFull(r, tmp2[:]); err != nil {
+		return err
+	}
+	suite := c.Powers.Suite
+	c.Contributions = make([]*Contribution, binary.LittleEndian.Uint16(tmp2[:]))
+	for i := range c.Contributions {
+		contr := &Contribution{
+			S1:     suite.G1().Point(),
+			PubKey: suite.G2().Point(),
+			R:      suite.G2().Point(),
+			Z:      suite.G2().Scalar(),
+		}
+		for _, p := range []kyber.Point{contr.S1, contr.PubKey, contr.R} {
+			if _, err := p.UnmarshalFrom(r); err != nil {
+				return err
+			}
+		}
+		if _, err := contr.Z.UnmarshalFrom(r); err != nil {
+			return err
+		}
+		c.Contributions[i] = contr
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package kzg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestCeremony(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	c, err := NewCeremony(suite, 20, 20)
+	require.NoError(t, err)
+	require.NoError(t, c.VerifyTranscript())
+	_, err = c.Finalize(16, suite.G1().Scalar().Zero())
+	require.Error(t, err)
+	require.Error(t, c.Contribute(suite.G1().Scalar().One()))
+
+	secret := suite.G1().Scalar().One()
+	for i := 0; i < 3; i++ {
+		cBack, err := CeremonyFromBytes(suite, c.Bytes())
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(c.Bytes(), cBack.Bytes()))
+		c = cBack
+
+		tau := suite.G1().Scalar().Pick(rnd)
+		secret.Mul(secret, tau)
+		require.NoError(t, c.Contribute(tau))
+		require.NoError(t, c.VerifyTranscript())
+	}
+	require.EqualValues(t, 3, len(c.Contributions))
+
+	tr, err := c.Finalize(16, suite.G1().Scalar().Zero())
+	require.NoError(t, err)
+	trSecret, err := TrustedSetupFromSecretNaturalDomain(suite, 16, secret)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(tr.Bytes(), trSecret.Bytes()))
+
+	t.Run("wrong", func(t *testing.T) {
+		cWrong, err := CeremonyFromBytes(suite, c.Bytes())
+		require.NoError(t, err)
+		cWrong.Contributions[1].PubKey = suite.G2().Point().Pick(rnd)
+		require.Error(t, cWrong.VerifyTranscript())
+
+		cWrong, _ = CeremonyFromBytes(suite, c.Bytes())
+		cWrong.Contributions[2].Z = suite.G1().Scalar().Pick(rnd)
+		require.Error(t, cWrong.VerifyTranscript())
+
+		cWrong, _ = CeremonyFromBytes(suite, c.Bytes())
+		cWrong.Powers.G1[5] = suite.G1().Point().Pick(rnd)
+		require.Error(t, cWrong.VerifyTranscript())
+
+		cWrong, _ = CeremonyFromBytes(suite, c.Bytes())
+		cWrong.Powers.G2[7] = suite.G2().Point().Pick(rnd)
+		require.Error(t, cWrong.VerifyTranscript())
+
+		cWrong, _ = CeremonyFromBytes(suite, c.Bytes())
+		cWrong.Powers.H[19] = suite.G1().Point().Pick(rnd)
+		require.Error(t, cWrong.VerifyTranscript())
+
+		// contribution dropped from the transcript
+		cWrong, _ = CeremonyFromBytes(suite, c.Bytes())
+		cWrong.Contributions = cWrong.Contributions[1:]
+		require.Error(t, cWrong.VerifyTranscript())
+	})
+}
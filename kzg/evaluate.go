@@ -0,0 +1,81 @@
+package kzg
+
+import (
+	"go.dedis.ch/kyber/v3"
+)
+
+// Openings at the arbitrary point z, not necessarily in the domain.
+// The value of the polynomial in evaluation form at z is calculated with the barycentric formula
+// f(z) = A(z)*sum<i>(vect<i>/(A'(domain<i>)*(z-domain<i>))), where A(X) = prod<i>(X-domain<i>).
+// The proof is pi = [(f(s)-y)/(s-z)]1. It is verified with e(pi, [s]2-[z]2) == e(C-[y]1, [1]2),
+// which needs [s]2 in the trusted setup
+
+// EvaluateAt returns the value at z of the polynomial in evaluation form given by the vector
+func (sd *TrustedSetup) EvaluateAt(vect []kyber.Scalar, z kyber.Scalar) kyber.Scalar {
+	if i := sd.domainIndex(z); i >= 0 {
+		if vect[i] == nil {
+			return sd.Suite.G1().Scalar().Zero()
+		}
+		return vect[i].Clone()
+	}
+	return sd.evaluateAt(vect, z, sd.invSubAt(z))
+}
+
+// ProveAt returns the value y = f(z) of the polynomial committed with Commit(vect) and the proof of it.
+// If z is in the domain, the proof is the same as of Prove
+func (sd *TrustedSetup) ProveAt(vect []kyber.Scalar, z kyber.Scalar) (kyber.Point, kyber.Scalar) {
+	if i := sd.domainIndex(z); i >= 0 {
+		return sd.Prove(vect, i), sd.EvaluateAt(vect, z)
+	}
+	y := sd.evaluateAt(vect, z, sd.invSubAt(z))
+	return sd.Commit(sd.quotientAt(vect, z, y)), y
+}
+
+// VerifyAt verifies proof pi that polynomial committed with c has value y at z
+func (sd *TrustedSetup) VerifyAt(c, pi kyber.Point, z, y kyber.Scalar) bool {
+	return sd.verifierKey().VerifyAt(c, pi, z, y)
+}
+
+// evaluateAt evaluates polynomial at z with the barycentric formula. inv<i> = 1/(z-domain<i>)
+func (sd *TrustedSetup) evaluateAt(vect []kyber.Scalar, z kyber.Scalar, inv []kyber.Scalar) kyber.Scalar {
+	var invAprime []kyber.Scalar
+	if sd.precalc != nil {
+		invAprime = sd.precalc.invAprime
+	} else {
+		invAprime = batchInverse(sd.Suite, sd.AprimeDomainI)
+	}
+	ret := sd.Suite.G1().Scalar().Zero()
+	t := sd.Suite.G1().Scalar()
+	for i, v := range vect {
+		if v == nil {
+			continue
+		}
+		t.Mul(v, invAprime[i])
+		ret.Add(ret, t.Mul(t, inv[i]))
+	}
+	// A(z)
+	a := sd.Suite.G1().Scalar().One()
+	for i := range sd.Domain {
+		a.Mul(a, t.Sub(z, sd.Domain[i]))
+	}
+	return ret.Mul(ret, a)
+}
+
+// invSubAt returns 1/(z-domain<i>) for all i, calculated with one field inversion. z must not be in the domain
+func (sd *TrustedSetup) invSubAt(z kyber.Scalar) []kyber.Scalar {
+	diffs := make([]kyber.Scalar, sd.D)
+	for i := range diffs {
+		diffs[i] = sd.Suite.G1().Scalar().Sub(z, sd.Domain[i])
+	}
+	return batchInverse(sd.Suite, diffs)
+}
+
+// domainIndex returns index of z in the domain or -1 if z is not in the domain
+func (sd *TrustedSetup) domainIndex(z kyber.Scalar) int {
+	for i := range sd.Domain {
+		if sd.Domain[i].Equal(z) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package kzg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestProveAt(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	vect := make([]kyber.Scalar, D)
+	for _, i := range []int{0, 3, 17, 100, 256} {
+		vect[i] = suite.G1().Scalar().Pick(rnd)
+	}
+	c := tr.Commit(vect)
+
+	z := suite.G1().Scalar().Pick(rnd)
+	expected := suite.G1().Scalar().Zero()
+	for i, v := range vect {
+		if v != nil {
+			expected.Add(expected, suite.G1().Scalar().Mul(v, tr.evalLagrangeValue(i, z)))
+		}
+	}
+	require.True(t, expected.Equal(tr.EvaluateAt(vect, z)))
+
+	pi, y := tr.ProveAt(vect, z)
+	require.True(t, y.Equal(expected))
+	require.True(t, tr.VerifyAt(c, pi, z, y))
+	require.False(t, tr.VerifyAt(c, pi, z, suite.G1().Scalar().Add(y, tr.OneG1)))
+	require.False(t, tr.VerifyAt(c, pi, suite.G1().Scalar().Pick(rnd), y))
+
+	// z in the domain
+	for _, i := range []int{3, 5} {
+		pi, y = tr.ProveAt(vect, tr.Domain[i])
+		require.True(t, y.Equal(valueOrZero(tr, vect[i])))
+		require.True(t, tr.VerifyAt(c, pi, tr.Domain[i], y))
+		require.True(t, tr.Verify(c, pi, y, i))
+	}
+}
+
+func TestSecretG2(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+	secret := suite.G1().Scalar().Pick(rnd)
+	tr, err := TrustedSetupFromSecretPowers(suite, D, omega, secret)
+	require.NoError(t, err)
+	require.True(t, tr.S2.Equal(suite.G2().Point().Mul(secret, nil)))
+
+	trBack, err := TrustedSetupFromBytes(suite, tr.Bytes())
+	require.NoError(t, err)
+	require.True(t, trBack.S2.Equal(tr.S2))
+	require.True(t, bytes.Equal(trBack.Bytes(), tr.Bytes()))
+
+	t.Run("derived", func(t *testing.T) {
+		trFile, err := TrustedSetupFromFile(suite, "example.setup")
+		require.NoError(t, err)
+		require.NotNil(t, trFile.S2)
+		require.True(t, trFile.S2.Equal(trFile.secretG2FromDiff2()))
+	})
+	t.Run("wrong", func(t *testing.T) {
+		trWrong, err := TrustedSetupFromBytes(suite, tr.Bytes())
+		require.NoError(t, err)
+		trWrong.S2 = suite.G2().Point().Pick(rnd)
+		_, err = TrustedSetupFromBytes(suite, trWrong.Bytes())
+		require.Error(t, err)
+	})
+}
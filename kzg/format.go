@@ -0,0 +1,296 @@
+package kzg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// The serialized trusted setup, version 2:
+// - magic "KZGS"
+// - version (byte) = 2
+// - suite ID: length (byte) followed by the name of the suite, "bn256"
+// - domain kind (byte): domainOmega or domainNatural
+// - D (uint16)
+// - omega, only for the omega domain
+// - Lagrange basis, D points of G1
+// - Diff2, D points of G2
+// - optional sections: tag (byte), size of the payload (uint32) and the payload.
+//   Sections with unknown tags are skipped, so new kinds of sections do not break older readers
+// - sectionEnd tag
+// - blake2b-256 hash of all previous bytes
+// The file without the magic is read as the format before version 2 (see readLegacy)
+
+const (
+	formatVersion = byte(2)
+	suiteID       = "bn256"
+	sectionEnd    = byte(0)
+)
+
+// domain kinds
+const (
+	domainOmega   = byte(0)
+	domainNatural = byte(1)
+)
+
+var formatMagic = []byte("KZGS")
+
+var (
+	errWrongFormat = xerrors.New("wrong format of the trusted setup")
+	errWrongHash   = xerrors.New("content hash of the trusted setup does not match")
+)
+
+// write marshals the trusted setup in the format version 2
+func (sd *TrustedSetup) write(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.Write(formatMagic)
+	if err := writeHeader(&buf, sd.D, sd.Omega); err != nil {
+		return err
+	}
+	if err := writePoints(&buf, sd.LagrangeBasis); err != nil {
+		return err
+	}
+	if err := writePoints(&buf, sd.Diff2); err != nil {
+		return err
+	}
+	// optional sections
+	if sd.LagrangeBasis2 != nil {
+		if err := writeSection(&buf, sectionLagrangeBasis2, func(w io.Writer) error {
+			return writePoints(w, sd.LagrangeBasis2)
+		}); err != nil {
+			return err
+		}
+	}
+	if sd.BlindingBasis != nil {
+		if err := writeSection(&buf, sectionBlindingBasis, func(w io.Writer) error {
+			return writePoints(w, sd.BlindingBasis)
+		}); err != nil {
+			return err
+		}
+	}
+	if sd.S2 != nil {
+		if err := writeSection(&buf, sectionSecretG2, func(w io.Writer) error {
+			_, err := sd.S2.MarshalTo(w)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	if sd.PowersOfTau != nil {
+		if err := writeSection(&buf, sectionPowersOfTau, sd.PowersOfTau.write); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(sectionEnd)
+	h := blake2b.Sum256(buf.Bytes())
+	buf.Write(h[:])
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// read unmarshals the trusted setup either in the format version 2 or in the legacy format
+func (sd *TrustedSetup) read(data []byte) error {
+	if !bytes.HasPrefix(data, formatMagic) {
+		return sd.readLegacy(bytes.NewReader(data))
+	}
+	content, err := checkContentHash(data, formatMagic)
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(content[len(formatMagic):])
+	d, omega, err := readHeader(sd.Suite, r)
+	if err != nil {
+		return err
+	}
+	sd.init(d)
+	sd.Omega.Set(omega)
+	if err := readPoints(r, sd.LagrangeBasis); err != nil {
+		return err
+	}
+	if err := readPoints(r, sd.Diff2); err != nil {
+		return err
+	}
+	for {
+		var tag [1]byte
+		if _, err := io.ReadFull(r, tag[:]); err != nil {
+			return err
+		}
+		if tag[0] == sectionEnd {
+			break
+		}
+		var tmp4 [4]byte
+		if _, err := io.ReadFull(r, tmp4[:]); err != nil {
+			return err
+		}
+		size := binary.LittleEndian.Uint32(tmp4[:])
+		if int64(size) > int64(r.Len()) {
+			return errWrongFormat
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		if err := sd.readSection(tag[0], payload); err != nil {
+			return err
+		}
+	}
+	if r.Len() != 0 {
+		return errWrongFormat
+	}
+	return nil
+}
+
+// readSection reads optional section of the format version 2. Unknown sections are ignored
+func (sd *TrustedSetup) readSection(tag byte, payload []byte) error {
+	r := bytes.NewReader(payload)
+	switch tag {
+	case sectionLagrangeBasis2:
+		sd.LagrangeBasis2 = make([]kyber.Point, sd.D)
+		for i := range sd.LagrangeBasis2 {
+			sd.LagrangeBasis2[i] = sd.Suite.G2().Point()
+		}
+		if err := readPoints(r, sd.LagrangeBasis2); err != nil {
+			return err
+		}
+	case sectionBlindingBasis:
+		sd.BlindingBasis = make([]kyber.Point, sd.D)
+		for i := range sd.BlindingBasis {
+			sd.BlindingBasis[i] = sd.Suite.G1().Point()
+		}
+		if err := readPoints(r, sd.BlindingBasis); err != nil {
+			return err
+		}
+	case sectionSecretG2:
+		sd.S2 = sd.Suite.G2().Point()
+		if _, err := sd.S2.UnmarshalFrom(r); err != nil {
+			return err
+		}
+	case sectionPowersOfTau:
+		pt := &PowersOfTau{Suite: sd.Suite}
+		if err := pt.read(r); err != nil {
+			return err
+		}
+		if err := pt.check(); err != nil {
+			return err
+		}
+		sd.PowersOfTau = pt
+	default:
+		return nil
+	}
+	if r.Len() != 0 {
+		return xerrors.Errorf("wrong size of the section %d: %w", tag, errWrongFormat)
+	}
+	return nil
+}
+
+// writeHeader writes version, suite ID, domain kind, D and omega
+func writeHeader(w io.Writer, d uint16, omega kyber.Scalar) error {
+	var buf bytes.Buffer
+	buf.WriteByte(formatVersion)
+	buf.WriteByte(byte(len(suiteID)))
+	buf.WriteString(suiteID)
+	var tmp2 [2]byte
+	binary.LittleEndian.PutUint16(tmp2[:], d)
+	if omega.Equal(omega.Clone().Zero()) {
+		buf.WriteByte(domainNatural)
+		buf.Write(tmp2[:])
+	} else {
+		buf.WriteByte(domainOmega)
+		buf.Write(tmp2[:])
+		if _, err := omega.MarshalTo(&buf); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readHeader reads header written by writeHeader. Returns D and omega, which is 0 for the natural domain
+func readHeader(suite *bn256.Suite, r io.Reader) (uint16, kyber.Scalar, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != formatVersion {
+		return 0, nil, xerrors.Errorf("unsupported format version %d", header[0])
+	}
+	id := make([]byte, header[1])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return 0, nil, err
+	}
+	if string(id) != suiteID {
+		return 0, nil, xerrors.Errorf("unsupported suite '%s'", string(id))
+	}
+	var domain [3]byte
+	if _, err := io.ReadFull(r, domain[:]); err != nil {
+		return 0, nil, err
+	}
+	omega := suite.G1().Scalar().Zero()
+	switch domain[0] {
+	case domainOmega:
+		if _, err := omega.UnmarshalFrom(r); err != nil {
+			return 0, nil, err
+		}
+		if !isRootOfUnity(suite, omega) {
+			return 0, nil, errNotROU
+		}
+	case domainNatural:
+	default:
+		return 0, nil, xerrors.Errorf("unknown domain kind %d", domain[0])
+	}
+	return binary.LittleEndian.Uint16(domain[1:]), omega, nil
+}
+
+// checkContentHash checks the magic and the content hash at the end of data. Returns data without the hash
+func checkContentHash(data, magic []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, magic) || len(data) < len(magic)+blake2b.Size256 {
+		return nil, errWrongFormat
+	}
+	content := data[:len(data)-blake2b.Size256]
+	if h := blake2b.Sum256(content); !bytes.Equal(h[:], data[len(content):]) {
+		return nil, errWrongHash
+	}
+	return content, nil
+}
+
+// writeSection writes the tag, the size and the payload written by writePayload
+func writeSection(w io.Writer, tag byte, writePayload func(w io.Writer) error) error {
+	var payload bytes.Buffer
+	if err := writePayload(&payload); err != nil {
+		return err
+	}
+	var tmp4 [4]byte
+	binary.LittleEndian.PutUint32(tmp4[:], uint32(payload.Len()))
+	if _, err := w.Write([]byte{tag}); err != nil {
+		return err
+	}
+	if _, err := w.Write(tmp4[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+func writePoints(w io.Writer, points []kyber.Point) error {
+	for _, p := range points {
+		if _, err := p.MarshalTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPoints unmarshals points into the already allocated slice
+func readPoints(r io.Reader, points []kyber.Point) error {
+	for _, p := range points {
+		if _, err := p.UnmarshalFrom(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
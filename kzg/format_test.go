@@ -0,0 +1,92 @@
+package kzg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestFormat(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+	tr, err := TrustedSetupFromSecretPowers(suite, D, omega, suite.G1().Scalar().Pick(rnd))
+	require.NoError(t, err)
+	data := tr.Bytes()
+	require.True(t, bytes.HasPrefix(data, formatMagic))
+
+	trBack, err := TrustedSetupFromBytes(suite, data)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, trBack.Bytes()))
+	require.True(t, trBack.Omega.Equal(omega))
+
+	// rehash replaces the content hash at the end of the data
+	rehash := func(data []byte) []byte {
+		content := data[:len(data)-blake2b.Size256]
+		h := blake2b.Sum256(content)
+		return append(append([]byte{}, content...), h[:]...)
+	}
+	t.Run("legacy", func(t *testing.T) {
+		legacy, err := ioutil.ReadFile("example.setup")
+		require.NoError(t, err)
+		require.False(t, bytes.HasPrefix(legacy, formatMagic))
+		trLegacy, err := TrustedSetupFromBytes(suite, legacy)
+		require.NoError(t, err)
+		trV2, err := TrustedSetupFromBytes(suite, trLegacy.Bytes())
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(trLegacy.Bytes(), trV2.Bytes()))
+
+		_, err = TrustedSetupFromBytes(suite, legacy[:1])
+		require.Error(t, err)
+		_, err = TrustedSetupFromBytes(suite, legacy[:len(legacy)-1])
+		require.Error(t, err)
+	})
+	t.Run("natural domain", func(t *testing.T) {
+		trNatural, err := TrustedSetupFromSeed(suite, 16, []byte("format"))
+		require.NoError(t, err)
+		trBack, err := TrustedSetupFromBytes(suite, trNatural.Bytes())
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(trNatural.Bytes(), trBack.Bytes()))
+		require.True(t, trBack.Domain[5].Equal(suite.G1().Scalar().SetInt64(5)))
+	})
+	t.Run("powers of tau", func(t *testing.T) {
+		pt, err := PowersOfTauFromSecret(suite, 20, 20, suite.G1().Scalar().Pick(rnd))
+		require.NoError(t, err)
+		trPowers, err := TrustedSetupFromPowersOfTau(pt, 16, suite.G1().Scalar().Zero())
+		require.NoError(t, err)
+		trPowers.PowersOfTau = pt
+		trBack, err := TrustedSetupFromBytes(suite, trPowers.Bytes())
+		require.NoError(t, err)
+		require.NotNil(t, trBack.PowersOfTau)
+		require.True(t, bytes.Equal(pt.Bytes(), trBack.PowersOfTau.Bytes()))
+	})
+	t.Run("unknown section", func(t *testing.T) {
+		content := data[:len(data)-blake2b.Size256-1]
+		withSection := append(append([]byte{}, content...), 200, 3, 0, 0, 0, 1, 2, 3, sectionEnd)
+		withSection = append(withSection, make([]byte, blake2b.Size256)...)
+		trBack, err := TrustedSetupFromBytes(suite, rehash(withSection))
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(data, trBack.Bytes()))
+	})
+	t.Run("wrong", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[100]++
+		_, err := TrustedSetupFromBytes(suite, corrupted)
+		require.Error(t, err)
+
+		wrongVersion := append([]byte{}, data...)
+		wrongVersion[len(formatMagic)] = 3
+		_, err = TrustedSetupFromBytes(suite, rehash(wrongVersion))
+		require.Error(t, err)
+
+		_, err = TrustedSetupFromBytes(suite, data[:len(data)-1])
+		require.Error(t, err)
+		_, err = TrustedSetupFromBytes(suite, rehash(append(data, 0)))
+		require.Error(t, err)
+	})
+}
@@ -9,32 +9,56 @@ import (
 // i.e. with f(rou[i]) = vect[i], i = 0..D-1
 // vect[k] == nil equivalent to 0
 func (sd *TrustedSetup) Commit(vect []kyber.Scalar) kyber.Point {
-	ret := sd.Suite.G1().Point().Null()
-	elem := sd.Suite.G1().Point()
-	for i, e := range vect {
-		if e == nil {
-			continue
-		}
-		elem.Mul(e, sd.LagrangeBasis[i])
-		ret.Add(ret, elem)
-	}
-	return ret
+	return sd.commitLagrange(vect)
 }
 
 // Prove returns pi = [(f(s)-vect<index>)/(s-rou<index>)]1
 // This is the proof sent to verifier
+// pi = sum<j>(q(domain<j>)*[l<j>(s)]1), where q(domain<j>) = (vect<j>-vect<i>)/(domain<j>-domain<i>) for j != i.
+// It is calculated as sum<j!=i, vect<j>!=nil>(vect<j>/(domain<j>-domain<i>)*[l<j>(s)]1) + q(domain<i>)*[l<i>(s)]1 - vect<i>*W<i>,
+// where W<i> = sum<j!=i>([l<j>(s)]1/(domain<j>-domain<i>)) is precalculated.
+// So the cost is proportional to the number of not nil elements of the vector
 func (sd *TrustedSetup) Prove(vect []kyber.Scalar, i int) kyber.Point {
-	ret := sd.Suite.G1().Point().Null()
-	e := sd.Suite.G1().Point()
-	qij := sd.Suite.G1().Scalar()
-	for j := range sd.Domain {
-		sd.qPoly(vect, i, j, vect[i], qij)
-		e.Mul(qij, sd.LagrangeBasis[j])
-		ret.Add(ret, e)
+	scalars := make([]kyber.Scalar, sd.D)
+	t := sd.Suite.G1().Scalar()
+	for j, v := range vect {
+		if j == i || v == nil {
+			continue
+		}
+		scalars[j] = sd.Suite.G1().Scalar().Mul(v, sd.invsub(j, i, t))
+	}
+	scalars[i] = sd.Suite.G1().Scalar()
+	sd.qPoly(vect, i, i, vect[i], scalars[i])
+	ret := sd.commitLagrange(scalars)
+	if vect[i] != nil {
+		ret.Sub(ret, sd.Suite.G1().Point().Mul(vect[i], sd.sparseBase(i)))
 	}
 	return ret
 }
 
+// sparseBase returns W<i> = sum<j!=i>([l<j>(s)]1/(domain<j>-domain<i>)).
+// Values are calculated upon first request
+func (sd *TrustedSetup) sparseBase(i int) kyber.Point {
+	sd.sparseMutex.Lock()
+	defer sd.sparseMutex.Unlock()
+
+	if sd.sparseBasis == nil {
+		sd.sparseBasis = make([]kyber.Point, sd.D)
+	}
+	if sd.sparseBasis[i] != nil {
+		return sd.sparseBasis[i]
+	}
+	scalars := make([]kyber.Scalar, sd.D)
+	for j := range scalars {
+		if j == i {
+			continue
+		}
+		scalars[j] = sd.invsub(j, i, sd.Suite.G1().Scalar())
+	}
+	sd.sparseBasis[i] = sd.commitLagrange(scalars)
+	return sd.sparseBasis[i]
+}
+
 func (sd *TrustedSetup) qPoly(vect []kyber.Scalar, i, m int, y kyber.Scalar, ret kyber.Scalar) {
 	numer := sd.Suite.G1().Scalar()
 	if i != m {
@@ -84,29 +108,35 @@ func (sd *TrustedSetup) diff(vi, vj kyber.Scalar, ret kyber.Scalar) {
 // value is the value of the polynomial
 // adIndex is index of the root of unity where polynomial is expected to have value = v
 func (sd *TrustedSetup) Verify(c, pi kyber.Point, v kyber.Scalar, atIndex int) bool {
-	p1 := sd.Suite.Pair(pi, sd.Diff2[atIndex])
-	e := sd.Suite.G1().Point().Mul(v, nil)
-	e.Sub(c, e)
-	p2 := sd.Suite.Pair(e, sd.Suite.G2().Point().Base())
-	return p1.Equal(p2)
+	return sd.verifierKey().Verify(c, pi, v, atIndex)
+}
+
+// Opening is a KZG proof Pi that polynomial committed with C has value Value at the domain point with index Index
+type Opening struct {
+	C     kyber.Point
+	Pi    kyber.Point
+	Value kyber.Scalar
+	Index int
 }
 
-// VerifyVector calculates proofs and verifies all elements in the vector against commitment C
+// BatchVerify verifies many openings at once (see BatchVerify)
+func (sd *TrustedSetup) BatchVerify(items []Opening) bool {
+	return BatchVerify(sd.verifierKey(), items)
+}
+
+// VerifyVector calculates proofs and verifies all elements in the vector against commitment C.
+// Proofs are calculated with ProveAll and verified with BatchVerify
 func (sd *TrustedSetup) VerifyVector(vect []kyber.Scalar, c kyber.Point) bool {
-	pi := make([]kyber.Point, sd.D)
-	for i := range vect {
-		pi[i] = sd.Prove(vect, i)
-	}
+	pi := sd.ProveAll(vect)
+	items := make([]Opening, sd.D)
 	for i := range pi {
 		v := vect[i]
 		if v == nil {
 			v = sd.ZeroG1
 		}
-		if !sd.Verify(c, pi[i], v, i) {
-			return false
-		}
+		items[i] = Opening{C: c, Pi: pi[i], Value: v, Index: i}
 	}
-	return true
+	return sd.BatchVerify(items)
 }
 
 // CommitAll return commit to the whole vector and to each of values of it
@@ -115,6 +145,21 @@ func (sd *TrustedSetup) VerifyVector(vect []kyber.Scalar, c kyber.Point) bool {
 func (sd *TrustedSetup) CommitAll(vect []kyber.Scalar) (kyber.Point, []kyber.Point) {
 	retC := sd.Commit(vect)
 	retPi := make([]kyber.Point, sd.D)
+	n := 0
+	for _, v := range vect {
+		if v != nil {
+			n++
+		}
+	}
+	if n >= proveAllThreshold {
+		all := sd.proveAllToeplitz(vect)
+		for i := range vect {
+			if vect[i] != nil {
+				retPi[i] = all[i]
+			}
+		}
+		return retC, retPi
+	}
 	for i := range vect {
 		if vect[i] == nil {
 			continue
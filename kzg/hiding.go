@@ -0,0 +1,101 @@
+package kzg
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+	"golang.org/x/xerrors"
+)
+
+// Hiding commitments use the blinding generator H, obtained by hashing to the curve, so nobody knows
+// its discrete logarithm with respect to the generator G of G1.
+// The hiding commitment to the vector is C = [f(s)]1 + r(s)*H, where r(X) is a random blinding polynomial
+// given in evaluation form by the blinding vector: r(domain<i>) = blinding<i>.
+// The proof of the value at index i is pi = [(f(s)-f(domain<i>))/(s-domain<i>)]1 + (r(s)-r(domain<i>))/(s-domain<i>)*H.
+// The opening reveals r(domain<i>), the commitment remains hiding with respect to all values which are not opened.
+// Hiding commitments need Lagrange basis on H, which is calculated from the secret together with the rest
+// of the trusted setup
+
+var errWrongBlindingBasis = xerrors.New("wrong blinding basis of the trusted setup")
+
+type hashablePoint interface {
+	Hash(data []byte) kyber.Point
+}
+
+// blindingGenerator is H
+var blindingGenerator = bn256.NewSuite().G1().Point().(hashablePoint).Hash([]byte("kzg blinding generator"))
+
+// generateBlindingBasis calculates Lagrange basis on the blinding generator: [l_i(s)]H
+func (sd *TrustedSetup) generateBlindingBasis(secret kyber.Scalar) {
+	sd.BlindingBasis = make([]kyber.Point, sd.D)
+	for i := range sd.BlindingBasis {
+		l := sd.evalLagrangeValue(i, secret)
+		sd.BlindingBasis[i] = sd.Suite.G1().Point().Mul(l, blindingGenerator)
+	}
+}
+
+// checkBlindingBasis checks if sum<i>([l_i(s)]H) == H, because sum<i>(l_i(X)) == 1
+func (sd *TrustedSetup) checkBlindingBasis() error {
+	sum := sd.Suite.G1().Point().Null()
+	for _, p := range sd.BlindingBasis {
+		sum.Add(sum, p)
+	}
+	if !sum.Equal(blindingGenerator) {
+		return errWrongBlindingBasis
+	}
+	return nil
+}
+
+// SupportsHiding returns true if the trusted setup contains Lagrange basis on the blinding generator
+func (sd *TrustedSetup) SupportsHiding() bool {
+	return sd.BlindingBasis != nil
+}
+
+// RandomBlinding returns random blinding vector
+func (sd *TrustedSetup) RandomBlinding() []kyber.Scalar {
+	rnd := random.New()
+	ret := make([]kyber.Scalar, sd.D)
+	for i := range ret {
+		ret[i] = sd.Suite.G1().Scalar().Pick(rnd)
+	}
+	return ret
+}
+
+// CommitBlinding returns the blinding term r(s)*H of the hiding commitment
+func (sd *TrustedSetup) CommitBlinding(blinding []kyber.Scalar) kyber.Point {
+	sd.blindingTableOnce.Do(func() {
+		sd.blindingBasisTable = newFixedBaseTable(sd.Suite.G1(), sd.BlindingBasis)
+	})
+	return sd.blindingBasisTable.mul(sd.BlindingBasis, blinding)
+}
+
+// CommitHiding returns hiding commitment C = [f(s)]1 + r(s)*H to the vector.
+// The trusted setup must support hiding
+func (sd *TrustedSetup) CommitHiding(vect, blinding []kyber.Scalar) kyber.Point {
+	ret := sd.Commit(vect)
+	return ret.Add(ret, sd.CommitBlinding(blinding))
+}
+
+// ProveHiding returns proof of the value vect<i> of the vector committed with CommitHiding.
+// The verifier also needs blinding<i>
+func (sd *TrustedSetup) ProveHiding(vect, blinding []kyber.Scalar, i int) kyber.Point {
+	q := make([]kyber.Scalar, sd.D)
+	for m := range q {
+		q[m] = sd.Suite.G1().Scalar()
+		sd.qPoly(blinding, i, m, blinding[i], q[m])
+	}
+	ret := sd.Prove(vect, i)
+	return ret.Add(ret, sd.CommitBlinding(q))
+}
+
+// VerifyHiding verifies proof pi that the vector committed with the hiding commitment c has value v at index i.
+// blindingValue is the value of the blinding vector at i. The check is e(pi, [s-domain<i>]2) == e(c-[v]1-blindingValue*H, [1]2)
+func (sd *TrustedSetup) VerifyHiding(c, pi kyber.Point, v, blindingValue kyber.Scalar, i int) bool {
+	return sd.Verify(sd.Unblind(c, blindingValue), pi, v, i)
+}
+
+// Unblind returns c - blindingValue*H. Proof of the hiding commitment c with the blinding value
+// is an ordinary proof of the returned commitment, so it can be verified with Verify or BatchVerify
+func (sd *TrustedSetup) Unblind(c kyber.Point, blindingValue kyber.Scalar) kyber.Point {
+	return sd.verifierKey().Unblind(c, blindingValue)
+}
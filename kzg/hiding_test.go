@@ -0,0 +1,56 @@
+package kzg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestHiding(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+	tr, err := TrustedSetupFromSecretPowers(suite, D, omega, suite.G1().Scalar().Pick(rnd))
+	require.NoError(t, err)
+	require.True(t, tr.SupportsHiding())
+
+	vect := make([]kyber.Scalar, D)
+	vect[5] = suite.G1().Scalar().SetInt64(5)
+	vect[256] = suite.G1().Scalar().SetInt64(1)
+	blinding := tr.RandomBlinding()
+	c := tr.CommitHiding(vect, blinding)
+	require.False(t, c.Equal(tr.Commit(vect)))
+	require.False(t, c.Equal(tr.CommitHiding(vect, tr.RandomBlinding())))
+
+	for _, i := range []int{5, 256, 0} {
+		pi := tr.ProveHiding(vect, blinding, i)
+		require.True(t, tr.VerifyHiding(c, pi, valueOrZero(tr, vect[i]), blinding[i], i))
+		require.False(t, tr.VerifyHiding(c, pi, suite.G1().Scalar().SetInt64(2), blinding[i], i))
+		require.False(t, tr.VerifyHiding(c, pi, valueOrZero(tr, vect[i]), blinding[(i+1)%D], i))
+		// the proof of the hiding commitment is not the proof of the commitment without blinding
+		require.False(t, tr.Verify(c, pi, valueOrZero(tr, vect[i]), i))
+		require.True(t, tr.BatchVerify([]Opening{{C: tr.Unblind(c, blinding[i]), Pi: pi, Value: vect[i], Index: i}}))
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		trBack, err := TrustedSetupFromBytes(suite, tr.Bytes())
+		require.NoError(t, err)
+		require.EqualValues(t, tr.Bytes(), trBack.Bytes())
+		require.True(t, trBack.SupportsHiding())
+		require.True(t, c.Equal(trBack.CommitHiding(vect, blinding)))
+
+		trWrong, err := TrustedSetupFromBytes(suite, tr.Bytes())
+		require.NoError(t, err)
+		trWrong.BlindingBasis[1] = trWrong.BlindingBasis[2]
+		_, err = TrustedSetupFromBytes(suite, trWrong.Bytes())
+		require.Error(t, err)
+	})
+	t.Run("not supported", func(t *testing.T) {
+		trFile, err := TrustedSetupFromFile(suite, "example.setup")
+		require.NoError(t, err)
+		require.False(t, trFile.SupportsHiding())
+	})
+}
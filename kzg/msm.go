@@ -0,0 +1,185 @@
+package kzg
+
+import (
+	"math/bits"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// multi-scalar multiplication sum<i>(scalars[i]*points[i])
+
+// below this number of not nil scalars points are multiplied one by one
+const msmNaiveThreshold = 5
+
+// fixed base table uses 8 bit windows, i.e. digits of the scalar are bytes of its big endian representation
+const fixedBaseWindowBits = 8
+
+// multiScalarMul calculates sum<i>(scalars[i]*points[i]) with Pippenger's bucket method.
+// Points are elements of the group, either G1 or G2
+// nil scalars are skipped
+func multiScalarMul(group kyber.Group, points []kyber.Point, scalars []kyber.Scalar) kyber.Point {
+	digits, n := scalarDigits(scalars)
+	if n < msmNaiveThreshold {
+		return mulNaive(group, points, scalars)
+	}
+	c := bits.Len(uint(n)) - 3
+	if c < 2 {
+		c = 2
+	}
+	numWindows := (group.ScalarLen()*8 + c - 1) / c
+	buckets := make([]kyber.Point, 1<<c)
+	ret := group.Point().Null()
+	for w := numWindows - 1; w >= 0; w-- {
+		for i := 0; i < c; i++ {
+			ret.Add(ret, ret)
+		}
+		for i := range buckets {
+			buckets[i] = nil
+		}
+		for i, d := range digits {
+			if d == nil {
+				continue
+			}
+			b := window(d, w*c, c)
+			if b == 0 {
+				continue
+			}
+			if buckets[b] == nil {
+				buckets[b] = points[i].Clone()
+			} else {
+				buckets[b].Add(buckets[b], points[i])
+			}
+		}
+		ret.Add(ret, sumBuckets(group, buckets))
+	}
+	return ret
+}
+
+// MultiScalarMul calculates sum<i>(scalars[i]*points[i]) in the group. nil scalars are skipped.
+// It is used by other commitment schemes over the same groups (see package ipa)
+func MultiScalarMul(group kyber.Group, points []kyber.Point, scalars []kyber.Scalar) kyber.Point {
+	return multiScalarMul(group, points, scalars)
+}
+
+// fixedBaseTable is a precalculated table for multi-scalar multiplication with fixed points
+// points[i][w] = 2^(8*w)*base<i>
+type fixedBaseTable struct {
+	group  kyber.Group
+	points [][]kyber.Point
+}
+
+func newFixedBaseTable(group kyber.Group, bases []kyber.Point) *fixedBaseTable {
+	numWindows := (group.ScalarLen()*8 + fixedBaseWindowBits - 1) / fixedBaseWindowBits
+	ret := &fixedBaseTable{
+		group:  group,
+		points: make([][]kyber.Point, len(bases)),
+	}
+	for i := range bases {
+		ret.points[i] = make([]kyber.Point, numWindows)
+		p := bases[i].Clone()
+		for w := range ret.points[i] {
+			ret.points[i][w] = p.Clone()
+			for k := 0; k < fixedBaseWindowBits; k++ {
+				p.Add(p, p)
+			}
+		}
+	}
+	return ret
+}
+
+// mul calculates sum<i>(scalars[i]*base<i>). Doublings are not needed, because all windows
+// of all scalars go into the same buckets
+func (t *fixedBaseTable) mul(bases []kyber.Point, scalars []kyber.Scalar) kyber.Point {
+	digits, n := scalarDigits(scalars)
+	if n < msmNaiveThreshold {
+		return mulNaive(t.group, bases, scalars)
+	}
+	buckets := make([]kyber.Point, 1<<fixedBaseWindowBits)
+	for i, d := range digits {
+		if d == nil {
+			continue
+		}
+		for k, b := range d {
+			if b == 0 {
+				continue
+			}
+			// big endian, byte k is the window len(d)-1-k
+			p := t.points[i][len(d)-1-k]
+			if buckets[b] == nil {
+				buckets[b] = p.Clone()
+			} else {
+				buckets[b].Add(buckets[b], p)
+			}
+		}
+	}
+	return sumBuckets(t.group, buckets)
+}
+
+// sumBuckets returns sum<d>(d*buckets[d])
+func sumBuckets(group kyber.Group, buckets []kyber.Point) kyber.Point {
+	ret := group.Point().Null()
+	running := group.Point().Null()
+	for d := len(buckets) - 1; d > 0; d-- {
+		if buckets[d] != nil {
+			running.Add(running, buckets[d])
+		}
+		ret.Add(ret, running)
+	}
+	return ret
+}
+
+func mulNaive(group kyber.Group, points []kyber.Point, scalars []kyber.Scalar) kyber.Point {
+	ret := group.Point().Null()
+	e := group.Point()
+	for i, s := range scalars {
+		if s == nil {
+			continue
+		}
+		ret.Add(ret, e.Mul(s, points[i]))
+	}
+	return ret
+}
+
+// scalarDigits returns big endian binary representations of scalars and the number of not nil scalars
+func scalarDigits(scalars []kyber.Scalar) ([][]byte, int) {
+	ret := make([][]byte, len(scalars))
+	n := 0
+	for i, s := range scalars {
+		if s == nil {
+			continue
+		}
+		var err error
+		if ret[i], err = s.MarshalBinary(); err != nil {
+			panic(err)
+		}
+		n++
+	}
+	return ret, n
+}
+
+// window returns c bits of the big endian number d starting from the bit start (counted from the least significant)
+func window(d []byte, start, c int) int {
+	ret := 0
+	for i := c - 1; i >= 0; i-- {
+		bit := start + i
+		byteIdx := len(d) - 1 - bit/8
+		ret <<= 1
+		if byteIdx >= 0 && d[byteIdx]&(1<<(bit%8)) != 0 {
+			ret |= 1
+		}
+	}
+	return ret
+}
+
+// lagrangeTable returns fixed base table of the Lagrange basis. It is calculated upon first request
+func (sd *TrustedSetup) lagrangeTable() *fixedBaseTable {
+	sd.lagrangeTableOnce.Do(func() {
+		sd.lagrangeBasisTable = newFixedBaseTable(sd.Suite.G1(), sd.LagrangeBasis)
+	})
+	return sd.lagrangeBasisTable
+}
+
+// commitLagrange returns sum<i>(scalars[i]*[l<i>(s)]1)
+func (sd *TrustedSetup) commitLagrange(scalars []kyber.Scalar) kyber.Point {
+	return sd.lagrangeTable().mul(sd.LagrangeBasis, scalars)
+}
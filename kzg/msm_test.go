@@ -0,0 +1,49 @@
+package kzg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestMultiScalarMul(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	for _, n := range []int{0, 1, 4, 5, 17, 100, 257} {
+		points := make([]kyber.Point, n)
+		scalars := make([]kyber.Scalar, n)
+		for i := range points {
+			points[i] = suite.G1().Point().Pick(rnd)
+			switch i % 7 {
+			case 0:
+				// nil is skipped
+			case 1:
+				scalars[i] = suite.G1().Scalar().Zero()
+			case 2:
+				scalars[i] = suite.G1().Scalar().SetInt64(-1)
+			default:
+				scalars[i] = suite.G1().Scalar().Pick(rnd)
+			}
+		}
+		expected := mulNaive(suite.G1(), points, scalars)
+		require.True(t, expected.Equal(multiScalarMul(suite.G1(), points, scalars)))
+		table := newFixedBaseTable(suite.G1(), points)
+		require.True(t, expected.Equal(table.mul(points, scalars)))
+	}
+}
+
+func TestCommitMSM(t *testing.T) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+	rnd := random.New()
+
+	vect := make([]kyber.Scalar, D)
+	for i := range vect {
+		vect[i] = tr.Suite.G1().Scalar().Pick(rnd)
+	}
+	require.True(t, tr.Commit(vect).Equal(mulNaive(suite.G1(), tr.LagrangeBasis, vect)))
+}
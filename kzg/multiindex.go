@@ -0,0 +1,151 @@
+package kzg
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"golang.org/x/xerrors"
+)
+
+// ProveMulti returns one proof for values of the vector at several indices.
+// pi = [q(s)]1, where q(X) = (f(X)-I(X))/Z(X), Z(X) is the vanishing polynomial of the index set
+// and I(X) is the polynomial interpolating values of the vector on the index set.
+// Indices must be distinct and in the domain, otherwise error is returned
+func (sd *TrustedSetup) ProveMulti(vect []kyber.Scalar, indices []int) (kyber.Point, error) {
+	if err := sd.checkIndexSet(indices); err != nil {
+		return nil, err
+	}
+	values := make([]kyber.Scalar, len(indices))
+	for k, i := range indices {
+		values[k] = vect[i]
+	}
+	interp := sd.interpolateOnSubset(values, indices)
+	// r(X) = f(X)-I(X) in evaluation form, r(domain<i>) == 0 on the index set
+	r := make([]kyber.Scalar, sd.D)
+	for m := range r {
+		r[m] = sd.Suite.G1().Scalar()
+		sd.diff(vect[m], interp[m], r[m])
+	}
+	inSubset := make([]bool, sd.D)
+	for _, i := range indices {
+		inSubset[i] = true
+	}
+	q := make([]kyber.Scalar, sd.D)
+	t := sd.Suite.G1().Scalar()
+	t1 := sd.Suite.G1().Scalar()
+	for m := range q {
+		q[m] = sd.Suite.G1().Scalar()
+		if !inSubset[m] {
+			q[m].Div(r[m], sd.vanishingAt(indices, m, -1, t))
+			continue
+		}
+		// on the index set q(domain<m>) = r'(domain<m>)/Z'(domain<m>)
+		q[m].Zero()
+		for j := range r {
+			if j == m || inSubset[j] {
+				continue
+			}
+			q[m].Add(q[m], t.Mul(r[j], sd.ta(m, j, t1)))
+		}
+		q[m].Div(q[m], sd.vanishingAt(indices, m, m, t))
+	}
+	return sd.Commit(q), nil
+}
+
+// checkIndexSet checks that the index set is not empty, and indices are distinct and in the domain
+func (sd *TrustedSetup) checkIndexSet(indices []int) error {
+	if len(indices) == 0 {
+		return xerrors.New("empty index set")
+	}
+	inSubset := make([]bool, sd.D)
+	for _, i := range indices {
+		if i < 0 || i >= int(sd.D) {
+			return xerrors.Errorf("index %d out of the domain", i)
+		}
+		if inSubset[i] {
+			return xerrors.Errorf("duplicate index %d", i)
+		}
+		inSubset[i] = true
+	}
+	return nil
+}
+
+// VerifyMulti verifies proof pi that the vector committed with c has values at indices:
+// e(pi, [Z(s)]2) * e(-(c-[I(s)]1), [1]2) == 1.
+// It requires the trusted setup with the Lagrange basis on G2, otherwise returns false.
+// The verifier key contains neither Lagrange basis, so multi-index proofs can't be verified with it.
+// nil value is equivalent to 0
+func (sd *TrustedSetup) VerifyMulti(c, pi kyber.Point, values []kyber.Scalar, indices []int) bool {
+	if sd.LagrangeBasis2 == nil || len(values) != len(indices) || sd.checkIndexSet(indices) != nil {
+		return false
+	}
+	inSubset := make([]bool, sd.D)
+	for _, i := range indices {
+		inSubset[i] = true
+	}
+	// [Z(s)]2 = sum<m>(Z(domain<m>)*[l<m>(s)]2)
+	z2 := sd.Suite.G2().Point().Null()
+	p2 := sd.Suite.G2().Point()
+	t := sd.Suite.G1().Scalar()
+	for m := range sd.LagrangeBasis2 {
+		if inSubset[m] {
+			continue
+		}
+		z2.Add(z2, p2.Mul(sd.vanishingAt(indices, m, -1, t), sd.LagrangeBasis2[m]))
+	}
+	// -(c-[I(s)]1)
+	e := sd.Suite.G1().Point().Sub(sd.Commit(sd.interpolateOnSubset(values, indices)), c)
+	return sd.verifierKey().pairingProductIsOne([]kyber.Point{pi, e}, []kyber.Point{z2, sd.Suite.G2().Point().Base()})
+}
+
+// interpolateOnSubset returns, in evaluation form, the polynomial I(X) of degree < len(indices)
+// which has values[k] at domain<indices[k]>
+func (sd *TrustedSetup) interpolateOnSubset(values []kyber.Scalar, indices []int) []kyber.Scalar {
+	ret := make([]kyber.Scalar, sd.D)
+	// denominators Z'(domain<indices[k]>)
+	zprime := make([]kyber.Scalar, len(indices))
+	for k, i := range indices {
+		zprime[k] = sd.vanishingAt(indices, i, i, sd.Suite.G1().Scalar())
+	}
+	inSubset := make([]bool, sd.D)
+	for k, i := range indices {
+		inSubset[i] = true
+		ret[i] = sd.Suite.G1().Scalar().Zero()
+		if values[k] != nil {
+			ret[i].Set(values[k])
+		}
+	}
+	z := sd.Suite.G1().Scalar()
+	t := sd.Suite.G1().Scalar()
+	for m := range ret {
+		if inSubset[m] {
+			continue
+		}
+		// I(x) = Z(x) * sum<k>(v<k>/((x-x<k>)*Z'(x<k>)))
+		ret[m] = sd.Suite.G1().Scalar().Zero()
+		for k, i := range indices {
+			if values[k] == nil {
+				continue
+			}
+			t.Sub(sd.Domain[m], sd.Domain[i])
+			t.Mul(t, zprime[k])
+			t.Div(values[k], t)
+			ret[m].Add(ret[m], t)
+		}
+		ret[m].Mul(ret[m], sd.vanishingAt(indices, m, -1, z))
+	}
+	return ret
+}
+
+// vanishingAt returns prod<k, indices[k] != except>(domain<m>-domain<indices[k]>)
+// With except == -1 it is the value of the vanishing polynomial Z(X) of the index set at domain<m>.
+// With except == m it is the value of Z'(X) at domain<m> for m in the index set
+func (sd *TrustedSetup) vanishingAt(indices []int, m, except int, ret kyber.Scalar) kyber.Scalar {
+	ret.One()
+	e := sd.Suite.G1().Scalar()
+	for _, i := range indices {
+		if i == except {
+			continue
+		}
+		ret.Mul(ret, e.Sub(sd.Domain[m], sd.Domain[i]))
+	}
+	return ret
+}
@@ -0,0 +1,78 @@
+package kzg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestMultiIndex(t *testing.T) {
+	suite := bn256.NewSuite()
+	omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+	secret := suite.G1().Scalar().Pick(random.New())
+	trPowers, err := TrustedSetupFromSecretPowers(suite, D, omega, secret)
+	require.NoError(t, err)
+	trNatural, err := TrustedSetupFromSecretNaturalDomain(suite, D, secret)
+	require.NoError(t, err)
+
+	for _, tr := range []*TrustedSetup{trPowers, trNatural} {
+		rnd := random.New()
+		vect := make([]kyber.Scalar, D)
+		for i := 0; i < D; i += 5 {
+			vect[i] = tr.Suite.G1().Scalar().Pick(rnd)
+		}
+		c := tr.Commit(vect)
+		valuesAt := func(indices []int) []kyber.Scalar {
+			ret := make([]kyber.Scalar, len(indices))
+			for k, i := range indices {
+				ret[k] = vect[i]
+			}
+			return ret
+		}
+		for _, indices := range [][]int{{0}, {256}, {1, 5}, {0, 5, 10, 256, 3}, {7, 100, 200, 201}} {
+			pi, err := tr.ProveMulti(vect, indices)
+			require.NoError(t, err)
+			values := valuesAt(indices)
+			require.True(t, tr.VerifyMulti(c, pi, values, indices))
+
+			values[0] = tr.Suite.G1().Scalar().SetInt64(42)
+			require.False(t, tr.VerifyMulti(c, pi, values, indices))
+		}
+		// single index multi-proof is the same as an ordinary proof
+		pi, err := tr.ProveMulti(vect, []int{10})
+		require.NoError(t, err)
+		require.True(t, pi.Equal(tr.Prove(vect, 10)))
+		require.True(t, tr.VerifyMulti(c, tr.Prove(vect, 10), valuesAt([]int{10}), []int{10}))
+
+		pi, err = tr.ProveMulti(vect, []int{1, 2, 3})
+		require.NoError(t, err)
+		require.False(t, tr.VerifyMulti(c, pi, valuesAt([]int{1, 2}), []int{1, 2}))
+		require.False(t, tr.VerifyMulti(c, pi, valuesAt([]int{1, 2, 4}), []int{1, 2, 4}))
+		require.False(t, tr.VerifyMulti(c, pi, valuesAt([]int{1, 1, 3}), []int{1, 1, 3}))
+
+		for _, indices := range [][]int{nil, {1, 1, 3}, {-1}, {3, D}} {
+			_, err = tr.ProveMulti(vect, indices)
+			require.Error(t, err)
+		}
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		trBack, err := TrustedSetupFromBytes(suite, trPowers.Bytes())
+		require.NoError(t, err)
+		require.EqualValues(t, trPowers.Bytes(), trBack.Bytes())
+		require.EqualValues(t, D, len(trBack.LagrangeBasis2))
+	})
+	t.Run("no Lagrange basis on G2", func(t *testing.T) {
+		tr, err := TrustedSetupFromFile(suite, "example.setup")
+		require.NoError(t, err)
+		require.Nil(t, tr.LagrangeBasis2)
+		vect := make([]kyber.Scalar, D)
+		vect[3] = tr.Suite.G1().Scalar().SetInt64(3)
+		pi, err := tr.ProveMulti(vect, []int{3, 4})
+		require.NoError(t, err)
+		require.False(t, tr.VerifyMulti(tr.Commit(vect), pi, []kyber.Scalar{vect[3], nil}, []int{3, 4}))
+	})
+}
@@ -0,0 +1,209 @@
+package kzg
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// AggregatedProof is a proof of many openings (C<k>, index<k>, value<k>) with one KZG opening
+// at the random point t. It implements the multiproof technique with random evaluation:
+// https://dankradfeist.de/ethereum/2021/06/18/pcs-multiproofs.html
+// Openings f<k>(domain<index<k>>) = y<k> are aggregated with powers of random r into
+// g(X) = sum<k>(r^k*(f<k>(X)-y<k>)/(X-domain<index<k>>)). Then it is enough to prove that
+// h(X)-g(X), where h(X) = sum<k>(r^k*f<k>(X)/(t-domain<index<k>>)), has value y = sum<k>(r^k*y<k>/(t-domain<index<k>>)) at t.
+// Both r and t are obtained with Fiat-Shamir heuristic
+type AggregatedProof struct {
+	D  kyber.Point // D = [g(s)]1, commitment to the aggregated quotient
+	Pi kyber.Point // Pi = [(h(s)-g(s)-y)/(s-t)]1, proof of the value of h(X)-g(X) at t
+}
+
+// MarshalBinary marshals the proof: D and Pi
+func (pr *AggregatedProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writePoints(&buf, []kyber.Point{pr.D, pr.Pi}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AggregatedProofFromBytes unmarshals the aggregated proof
+func AggregatedProofFromBytes(suite *bn256.Suite, data []byte) (*AggregatedProof, error) {
+	ret := &AggregatedProof{
+		D:  suite.G1().Point(),
+		Pi: suite.G1().Point(),
+	}
+	r := bytes.NewReader(data)
+	if err := readPoints(r, []kyber.Point{ret.D, ret.Pi}); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, xerrors.New("wrong format of the aggregated proof")
+	}
+	return ret, nil
+}
+
+// ProveAggregated creates aggregated proof for the values of vectors vects[k] at indices[k].
+// commitments[k] must be a commitment to vects[k]
+func (sd *TrustedSetup) ProveAggregated(commitments []kyber.Point, vects [][]kyber.Scalar, indices []int) *AggregatedProof {
+	values := make([]kyber.Scalar, len(vects))
+	for k := range vects {
+		values[k] = vects[k][indices[k]]
+		if values[k] == nil {
+			values[k] = sd.ZeroG1
+		}
+	}
+	vk := sd.verifierKey()
+	r := vk.challengeR(commitments, indices, values)
+
+	// g(X) in evaluation form
+	g := make([]kyber.Scalar, sd.D)
+	for m := range g {
+		g[m] = sd.Suite.G1().Scalar().Zero()
+	}
+	rk := sd.Suite.G1().Scalar().One()
+	q := sd.Suite.G1().Scalar()
+	for k := range vects {
+		for m := range g {
+			sd.qPoly(vects[k], indices[k], m, values[k], q)
+			g[m].Add(g[m], q.Mul(q, rk))
+		}
+		rk.Mul(rk, r)
+	}
+	ret := &AggregatedProof{
+		D: sd.Commit(g),
+	}
+	t := vk.challengeT(r, ret.D)
+
+	// coefficients r^k/(t-domain<index<k>>) and value y
+	coeff := vk.aggregationCoefficients(r, t, indices)
+	y := sd.Suite.G1().Scalar().Zero()
+	e := sd.Suite.G1().Scalar()
+	for k := range values {
+		y.Add(y, e.Mul(coeff[k], values[k]))
+	}
+	// p(X) = h(X)-g(X) in evaluation form
+	p := make([]kyber.Scalar, sd.D)
+	for m := range p {
+		p[m] = sd.Suite.G1().Scalar().Neg(g[m])
+	}
+	for k := range vects {
+		for m, v := range vects[k] {
+			if v == nil {
+				continue
+			}
+			p[m].Add(p[m], e.Mul(coeff[k], v))
+		}
+	}
+	ret.Pi = sd.Commit(sd.quotientAt(p, t, y))
+	return ret
+}
+
+// VerifyAggregated verifies aggregated proof that vectors committed with commitments[k]
+// have values[k] at indices[k]. It takes one pairing check for any number of openings
+func (sd *TrustedSetup) VerifyAggregated(commitments []kyber.Point, indices []int, values []kyber.Scalar, proof *AggregatedProof) bool {
+	return sd.verifierKey().VerifyAggregated(commitments, indices, values, proof)
+}
+
+// VerifyAggregated verifies aggregated proof that vectors committed with commitments[k]
+// have values[k] at indices[k]. It takes one pairing check for any number of openings
+func (vk *VerifierKey) VerifyAggregated(commitments []kyber.Point, indices []int, values []kyber.Scalar, proof *AggregatedProof) bool {
+	if len(commitments) != len(indices) || len(commitments) != len(values) {
+		return false
+	}
+	for _, idx := range indices {
+		if idx < 0 || idx >= int(vk.D) {
+			return false
+		}
+	}
+	r := vk.challengeR(commitments, indices, values)
+	t := vk.challengeT(r, proof.D)
+	coeff := vk.aggregationCoefficients(r, t, indices)
+
+	// E - D - [y]1, where E = [h(s)]1 = sum<k>(r^k/(t-domain<index<k>>)*C<k>)
+	e := multiScalarMul(vk.Suite.G1(), commitments, coeff)
+	e.Sub(e, proof.D)
+	y := vk.Suite.G1().Scalar().Zero()
+	s := vk.Suite.G1().Scalar()
+	for k := range commitments {
+		y.Add(y, s.Mul(coeff[k], values[k]))
+	}
+	e.Sub(e, vk.Suite.G1().Point().Mul(y, nil))
+	return vk.verifyAt(e, proof.Pi, t)
+}
+
+// secretG2 returns a copy of [s]2
+func (sd *TrustedSetup) secretG2() kyber.Point {
+	if sd.S2 == nil {
+		return sd.secretG2FromDiff2()
+	}
+	return sd.S2.Clone()
+}
+
+// secretG2FromDiff2 restores [s]2 from [s-domain<0>]2
+func (sd *TrustedSetup) secretG2FromDiff2() kyber.Point {
+	ret := sd.Suite.G2().Point().Mul(sd.Domain[0], nil)
+	return ret.Add(ret, sd.Diff2[0])
+}
+
+// quotientAt returns polynomial (p(X)-y)/(X-t) in evaluation form, where y = p(t) and t is not in the domain
+func (sd *TrustedSetup) quotientAt(p []kyber.Scalar, t, y kyber.Scalar) []kyber.Scalar {
+	inv := sd.invSubAt(t)
+	ret := make([]kyber.Scalar, sd.D)
+	for m := range ret {
+		ret[m] = sd.Suite.G1().Scalar()
+		if p[m] == nil {
+			ret[m].Set(y)
+		} else {
+			ret[m].Sub(y, p[m])
+		}
+		ret[m].Mul(ret[m], inv[m])
+	}
+	return ret
+}
+
+// aggregationCoefficients returns r^k/(t-domain<index<k>>)
+func (vk *VerifierKey) aggregationCoefficients(r, t kyber.Scalar, indices []int) []kyber.Scalar {
+	ret := make([]kyber.Scalar, len(indices))
+	rk := vk.Suite.G1().Scalar().One()
+	d := vk.Suite.G1().Scalar()
+	for k, idx := range indices {
+		d.Sub(t, vk.Domain[idx])
+		ret[k] = vk.Suite.G1().Scalar().Div(rk, d)
+		rk.Mul(rk, r)
+	}
+	return ret
+}
+
+// challengeR is a Fiat-Shamir challenge r = hash(C<0>, index<0>, y<0>, ...., C<k>, index<k>, y<k>)
+func (vk *VerifierKey) challengeR(commitments []kyber.Point, indices []int, values []kyber.Scalar) kyber.Scalar {
+	h, _ := blake2b.New256(nil)
+	var tmp2 [2]byte
+	for k := range commitments {
+		if _, err := commitments[k].MarshalTo(h); err != nil {
+			panic(err)
+		}
+		binary.LittleEndian.PutUint16(tmp2[:], uint16(indices[k]))
+		_, _ = h.Write(tmp2[:])
+		if _, err := values[k].MarshalTo(h); err != nil {
+			panic(err)
+		}
+	}
+	return vk.Suite.G1().Scalar().SetBytes(h.Sum(nil))
+}
+
+// challengeT is a Fiat-Shamir challenge t = hash(r, D)
+func (vk *VerifierKey) challengeT(r kyber.Scalar, d kyber.Point) kyber.Scalar {
+	h, _ := blake2b.New256(nil)
+	if _, err := r.MarshalTo(h); err != nil {
+		panic(err)
+	}
+	if _, err := d.MarshalTo(h); err != nil {
+		panic(err)
+	}
+	return vk.Suite.G1().Scalar().SetBytes(h.Sum(nil))
+}
@@ -0,0 +1,97 @@
+package kzg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func randomVectors(tr *TrustedSetup, num int) ([][]kyber.Scalar, []kyber.Point) {
+	rnd := random.New()
+	vects := make([][]kyber.Scalar, num)
+	commitments := make([]kyber.Point, num)
+	for k := range vects {
+		vects[k] = make([]kyber.Scalar, D)
+		for i := 0; i < 10; i++ {
+			vects[k][(k*31+i*17)%D] = tr.Suite.G1().Scalar().Pick(rnd)
+		}
+		commitments[k] = tr.Commit(vects[k])
+	}
+	return vects, commitments
+}
+
+func valuesAt(tr *TrustedSetup, vects [][]kyber.Scalar, indices []int) []kyber.Scalar {
+	ret := make([]kyber.Scalar, len(vects))
+	for k := range vects {
+		ret[k] = vects[k][indices[k]]
+		if ret[k] == nil {
+			ret[k] = tr.ZeroG1
+		}
+	}
+	return ret
+}
+
+func TestAggregated(t *testing.T) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	t.Run("one opening", func(t *testing.T) {
+		vects, commitments := randomVectors(tr, 1)
+		indices := []int{31}
+		proof := tr.ProveAggregated(commitments, vects, indices)
+		require.True(t, tr.VerifyAggregated(commitments, indices, valuesAt(tr, vects, indices), proof))
+	})
+	t.Run("many openings", func(t *testing.T) {
+		vects, commitments := randomVectors(tr, 5)
+		indices := []int{0, 48, 2, 256, 100}
+		values := valuesAt(tr, vects, indices)
+		proof := tr.ProveAggregated(commitments, vects, indices)
+		require.True(t, tr.VerifyAggregated(commitments, indices, values, proof))
+
+		data, err := proof.MarshalBinary()
+		require.NoError(t, err)
+		proof1, err := AggregatedProofFromBytes(suite, data)
+		require.NoError(t, err)
+		require.True(t, tr.VerifyAggregated(commitments, indices, values, proof1))
+		_, err = AggregatedProofFromBytes(suite, data[:len(data)-1])
+		require.Error(t, err)
+
+		values[2] = tr.Suite.G1().Scalar().SetInt64(42)
+		require.False(t, tr.VerifyAggregated(commitments, indices, values, proof))
+	})
+	t.Run("same vector", func(t *testing.T) {
+		vects, commitments := randomVectors(tr, 1)
+		vects = [][]kyber.Scalar{vects[0], vects[0], vects[0]}
+		commitments = []kyber.Point{commitments[0], commitments[0], commitments[0]}
+		indices := []int{0, 17, 256}
+		values := valuesAt(tr, vects, indices)
+		proof := tr.ProveAggregated(commitments, vects, indices)
+		require.True(t, tr.VerifyAggregated(commitments, indices, values, proof))
+
+		indices[1] = 18
+		require.False(t, tr.VerifyAggregated(commitments, indices, values, proof))
+	})
+	t.Run("wrong commitment", func(t *testing.T) {
+		vects, commitments := randomVectors(tr, 3)
+		indices := []int{1, 2, 3}
+		values := valuesAt(tr, vects, indices)
+		proof := tr.ProveAggregated(commitments, vects, indices)
+		commitments[1], commitments[2] = commitments[2], commitments[1]
+		require.False(t, tr.VerifyAggregated(commitments, indices, values, proof))
+	})
+	t.Run("powers domain", func(t *testing.T) {
+		omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+		secret := suite.G1().Scalar().Pick(random.New())
+		trp, err := TrustedSetupFromSecretPowers(suite, D, omega, secret)
+		require.NoError(t, err)
+		vects, commitments := randomVectors(trp, 4)
+		indices := []int{256, 255, 0, 7}
+		values := valuesAt(trp, vects, indices)
+		proof := trp.ProveAggregated(commitments, vects, indices)
+		require.True(t, trp.VerifyAggregated(commitments, indices, values, proof))
+	})
+}
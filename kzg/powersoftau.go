@@ -0,0 +1,240 @@
+package kzg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"golang.org/x/xerrors"
+)
+
+// PowersOfTau is the trusted setup in the monomial form: powers of the secret s on the curves.
+// It does not depend on the domain, so one set of powers serves trusted setups
+// of any degree D <= len(G1) and any domain (see TrustedSetupFromPowersOfTau).
+// The secret is not needed for that
+type PowersOfTau struct {
+	Suite *bn256.Suite
+	G1    []kyber.Point // [s^i]1, i = 0..N-1
+	// G2 = [s^i]2, i = 0..M-1, M >= 2. [1]2 and [s]2 are enough for the proofs.
+	// M >= D is needed to derive LagrangeBasis2 of the trusted setup with degree D
+	G2 []kyber.Point
+	// H is optional. [s^i]H, where H is the blinding generator, i = 0..N-1. Needed to derive BlindingBasis
+	H []kyber.Point
+}
+
+var (
+	errNotEnoughPowers = xerrors.New("not enough powers of tau")
+	errWrongPowers     = xerrors.New("wrong powers of tau")
+)
+
+// PowersOfTauFromSecret calculates n powers of the secret on G1 and on the blinding generator and n2 powers on G2.
+// Only used once after what secret must be destroyed
+func PowersOfTauFromSecret(suite *bn256.Suite, n, n2 uint16, secret kyber.Scalar) (*PowersOfTau, error) {
+	if len(secret.String()) < 50 {
+		return nil, errWrongSecret
+	}
+	if n < 1 || n2 < 2 {
+		return nil, errNotEnoughPowers
+	}
+	ret := &PowersOfTau{
+		Suite: suite,
+		G1:    make([]kyber.Point, n),
+		G2:    make([]kyber.Point, n2),
+		H:     make([]kyber.Point, n),
+	}
+	e := suite.G1().Scalar().One()
+	for i := range ret.G1 {
+		ret.G1[i] = suite.G1().Point().Mul(e, nil)
+		ret.H[i] = suite.G1().Point().Mul(e, blindingGenerator)
+		e.Mul(e, secret)
+	}
+	e.One()
+	for i := range ret.G2 {
+		ret.G2[i] = suite.G2().Point().Mul(e, nil)
+		e.Mul(e, secret)
+	}
+	return ret, nil
+}
+
+// PowersOfTauFromBytes unmarshals powers of tau from binary representation
+func PowersOfTauFromBytes(suite *bn256.Suite, data []byte) (*PowersOfTau, error) {
+	ret := &PowersOfTau{Suite: suite}
+	r := bytes.NewReader(data)
+	if err := ret.read(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errWrongPowers
+	}
+	if err := ret.check(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PowersOfTauFromFile restores powers of tau from file
+func PowersOfTauFromFile(suite *bn256.Suite, fname string) (*PowersOfTau, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return PowersOfTauFromBytes(suite, data)
+}
+
+// Bytes marshals powers of tau
+func (pt *PowersOfTau) Bytes() []byte {
+	var buf bytes.Buffer
+	if err := pt.write(&buf); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// check checks the structure of the powers: the first power is the generator of the group.
+// It does not check if the points are consecutive powers of the same secret
+func (pt *PowersOfTau) check() error {
+	if len(pt.G1) < 1 || len(pt.G2) < 2 {
+		return errNotEnoughPowers
+	}
+	if len(pt.H) != 0 && len(pt.H) != len(pt.G1) {
+		return errWrongPowers
+	}
+	if !pt.G1[0].Equal(pt.Suite.G1().Point().Base()) || !pt.G2[0].Equal(pt.Suite.G2().Point().Base()) {
+		return errWrongPowers
+	}
+	if len(pt.H) != 0 && !pt.H[0].Equal(blindingGenerator) {
+		return errWrongPowers
+	}
+	return nil
+}
+
+// write marshals each of G1, G2 and H as number of points (uint16) followed by points
+func (pt *PowersOfTau) write(w io.Writer) error {
+	for _, points := range [][]kyber.Point{pt.G1, pt.G2, pt.H} {
+		var tmp2 [2]byte
+		binary.LittleEndian.PutUint16(tmp2[:], uint16(len(points)))
+		if _, err := w.Write(tmp2[:]); err != nil {
+			return err
+		}
+		for _, p := range points {
+			if _, err := p.MarshalTo(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pt *PowersOfTau) read(r io.Reader) error {
+	groups := []kyber.Group{pt.Suite.G1(), pt.Suite.G2(), pt.Suite.G1()}
+	for k, group := range groups {
+		var tmp2 [2]byte
+		if _, err := io.ReadFull(r, tmp2[:]); err != nil {
+			return err
+		}
+		points := make([]kyber.Point, binary.LittleEndian.Uint16(tmp2[:]))
+		for i := range points {
+			points[i] = group.Point()
+			if _, err := points[i].UnmarshalFrom(r); err != nil {
+				return err
+			}
+		}
+		switch k {
+		case 0:
+			pt.G1 = points
+		case 1:
+			pt.G2 = points
+		case 2:
+			pt.H = points
+		}
+	}
+	return nil
+}
+
+// TrustedSetupFromPowersOfTau derives the trusted setup of degree d from the powers of tau.
+// The domain is defined by omega as in TrustedSetupFromSecretPowers, or, if omega == 0, it is 0, 1, 2, ..., d-1.
+// The Lagrange basis is [l<i>(s)] = sum<k>(c<i,k>*[s^k]), where c<i,k> are coefficients of the polynomial
+// l<i>(X) = A(X)/((X-domain<i>)*A'(domain<i>)), A(X) = prod<j>(X-domain<j>).
+// LagrangeBasis2 and BlindingBasis are derived if there are enough powers of tau for them
+func TrustedSetupFromPowersOfTau(pt *PowersOfTau, d uint16, omega kyber.Scalar) (*TrustedSetup, error) {
+	if d < 1 || int(d) > len(pt.G1) {
+		return nil, errNotEnoughPowers
+	}
+	if err := pt.check(); err != nil {
+		return nil, err
+	}
+	ret := newTrustedSetup(pt.Suite)
+	ret.init(d)
+	ret.Omega.Set(omega)
+	if !omega.Equal(ret.ZeroG1) && !isRootOfUnity(ret.Suite, omega) {
+		return nil, errNotROU
+	}
+	if err := ret.initDomain(); err != nil {
+		return nil, err
+	}
+	coeffs := ret.lagrangeCoefficients()
+
+	table := newFixedBaseTable(ret.Suite.G1(), pt.G1[:d])
+	for i := range ret.LagrangeBasis {
+		ret.LagrangeBasis[i] = table.mul(pt.G1[:d], coeffs[i])
+	}
+	ret.S2 = pt.G2[1].Clone()
+	for i := range ret.Diff2 {
+		ret.Diff2[i] = ret.Suite.G2().Point().Mul(ret.Domain[i], nil)
+		ret.Diff2[i].Sub(ret.S2, ret.Diff2[i])
+	}
+	if len(pt.G2) >= int(d) {
+		table2 := newFixedBaseTable(ret.Suite.G2(), pt.G2[:d])
+		ret.LagrangeBasis2 = make([]kyber.Point, d)
+		for i := range ret.LagrangeBasis2 {
+			ret.LagrangeBasis2[i] = table2.mul(pt.G2[:d], coeffs[i])
+		}
+	}
+	if len(pt.H) >= int(d) {
+		tableH := newFixedBaseTable(ret.Suite.G1(), pt.H[:d])
+		ret.BlindingBasis = make([]kyber.Point, d)
+		for i := range ret.BlindingBasis {
+			ret.BlindingBasis[i] = tableH.mul(pt.H[:d], coeffs[i])
+		}
+	}
+	ret.precalculate()
+	return ret, nil
+}
+
+// lagrangeCoefficients returns coefficients c<i,k> of Lagrange polynomials l<i>(X) = sum<k>(c<i,k>*X^k).
+// A(X)/(X-domain<i>) is calculated with synthetic division
+func (sd *TrustedSetup) lagrangeCoefficients() [][]kyber.Scalar {
+	d := int(sd.D)
+	// coefficients of A(X), a[d] = 1
+	a := make([]kyber.Scalar, d+1)
+	a[0] = sd.Suite.G1().Scalar().One()
+	for k := 1; k <= d; k++ {
+		a[k] = sd.Suite.G1().Scalar().Zero()
+	}
+	t := sd.Suite.G1().Scalar()
+	for j := 0; j < d; j++ {
+		// multiply by (X-domain<j>)
+		for k := j + 1; k > 0; k-- {
+			a[k].Sub(a[k-1], t.Mul(a[k], sd.Domain[j]))
+		}
+		a[0].Mul(a[0], t.Neg(sd.Domain[j]))
+	}
+	invAprime := batchInverse(sd.Suite, sd.AprimeDomainI)
+	ret := make([][]kyber.Scalar, d)
+	for i := range ret {
+		q := make([]kyber.Scalar, d)
+		q[d-1] = a[d].Clone()
+		for k := d - 1; k > 0; k-- {
+			q[k-1] = sd.Suite.G1().Scalar().Mul(q[k], sd.Domain[i])
+			q[k-1].Add(q[k-1], a[k])
+		}
+		for k := range q {
+			q[k].Mul(q[k], invAprime[i])
+		}
+		ret[i] = q
+	}
+	return ret
+}
@@ -0,0 +1,69 @@
+package kzg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestPowersOfTau(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	secret := suite.G1().Scalar().Pick(rnd)
+	pt, err := PowersOfTauFromSecret(suite, 300, 20, secret)
+	require.NoError(t, err)
+
+	ptBack, err := PowersOfTauFromBytes(suite, pt.Bytes())
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(pt.Bytes(), ptBack.Bytes()))
+
+	t.Run("same as from secret", func(t *testing.T) {
+		omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+		tr, err := TrustedSetupFromPowersOfTau(pt, D, omega)
+		require.NoError(t, err)
+		trSecret, err := TrustedSetupFromSecretPowers(suite, D, omega, secret)
+		require.NoError(t, err)
+		require.Nil(t, tr.LagrangeBasis2)
+		trSecret.LagrangeBasis2 = nil
+		require.True(t, bytes.Equal(tr.Bytes(), trSecret.Bytes()))
+	})
+	t.Run("any degree", func(t *testing.T) {
+		for _, d := range []uint16{1, 16, 300} {
+			tr, err := TrustedSetupFromPowersOfTau(pt, d, suite.G1().Scalar().Zero())
+			require.NoError(t, err)
+			require.Equal(t, d >= 20, tr.LagrangeBasis2 == nil)
+			require.NoError(t, tr.checkBlindingBasis())
+
+			vect := make([]kyber.Scalar, d)
+			vect[0] = suite.G1().Scalar().Pick(rnd)
+			vect[d-1] = suite.G1().Scalar().Pick(rnd)
+			c := tr.Commit(vect)
+			for _, i := range []int{0, int(d) - 1} {
+				require.True(t, tr.Verify(c, tr.Prove(vect, i), vect[i], i))
+			}
+		}
+	})
+	t.Run("multi-index", func(t *testing.T) {
+		tr, err := TrustedSetupFromPowersOfTau(pt, 16, suite.G1().Scalar().Zero())
+		require.NoError(t, err)
+		trSecret, err := TrustedSetupFromSecretNaturalDomain(suite, 16, secret)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(tr.Bytes(), trSecret.Bytes()))
+	})
+	t.Run("wrong", func(t *testing.T) {
+		_, err := TrustedSetupFromPowersOfTau(pt, 301, suite.G1().Scalar().Zero())
+		require.Error(t, err)
+		_, err = TrustedSetupFromPowersOfTau(pt, D, suite.G1().Scalar().Pick(rnd))
+		require.Error(t, err)
+		_, err = PowersOfTauFromBytes(suite, append(pt.Bytes(), 0))
+		require.Error(t, err)
+		ptWrong := *pt
+		ptWrong.G1 = append([]kyber.Point{suite.G1().Point().Pick(rnd)}, pt.G1[1:]...)
+		_, err = PowersOfTauFromBytes(suite, ptWrong.Bytes())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+package kzg
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"go.dedis.ch/kyber/v3"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// Precalculated values of the trusted setup can be stored in the sidecar file next to the file of the trusted setup.
+// The sidecar file contains:
+// - blake2b hash of the binary representation of the trusted setup it belongs to
+// - 1/(domain<m>-domain<j>) for all m > j, in the order m = 1..D-1, j = 0..m-1
+// - points W<i> = sum<j!=i>([l<j>(s)]1/(domain<j>-domain<i>)) used by Prove, i = 0..D-1
+// - blake2b hash of all the above
+
+const precalcFileSuffix = ".precalc"
+
+var errWrongPrecalc = xerrors.New("wrong size of precalculated data")
+
+// PrecalcFileName returns name of the sidecar file with precalculated values of the trusted setup stored in fname
+func PrecalcFileName(fname string) string {
+	return fname + precalcFileSuffix
+}
+
+// WritePrecalcFile calculates all precalculated values of the trusted setup and saves them into the sidecar
+// file of the trusted setup file fname. TrustedSetupFromFile reads the sidecar file instead of calculating them
+func (sd *TrustedSetup) WritePrecalcFile(fname string) error {
+	var buf bytes.Buffer
+	if err := sd.writePrecalc(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(PrecalcFileName(fname), buf.Bytes(), 0600)
+}
+
+func (sd *TrustedSetup) writePrecalc(out io.Writer) error {
+	contentHash, _ := blake2b.New256(nil)
+	w := io.MultiWriter(out, contentHash)
+	h := blake2b.Sum256(sd.Bytes())
+	if _, err := w.Write(h[:]); err != nil {
+		return err
+	}
+	for m := 1; m < int(sd.D); m++ {
+		for j := 0; j < m; j++ {
+			if _, err := sd.invsub(m, j).MarshalTo(w); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range sd.sparseBases() {
+		if _, err := p.MarshalTo(w); err != nil {
+			return err
+		}
+	}
+	_, err := out.Write(contentHash.Sum(nil))
+	return err
+}
+
+// readPrecalc reads precalculated values. Returns false if they belong to another trusted setup
+// or do not match the content hash
+func (sd *TrustedSetup) readPrecalc(in io.Reader) (bool, error) {
+	contentHash, _ := blake2b.New256(nil)
+	r := io.TeeReader(in, contentHash)
+	var h [32]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return false, err
+	}
+	if h != blake2b.Sum256(sd.Bytes()) {
+		return false, nil
+	}
+	invLower := make([]kyber.Scalar, int(sd.D)*(int(sd.D)-1)/2)
+	for k := range invLower {
+		invLower[k] = sd.Suite.G1().Scalar()
+		if _, err := invLower[k].UnmarshalFrom(r); err != nil {
+			return false, err
+		}
+	}
+	sparseBasis := make([]kyber.Point, sd.D)
+	for i := range sparseBasis {
+		sparseBasis[i] = sd.Suite.G1().Point()
+		if _, err := sparseBasis[i].UnmarshalFrom(r); err != nil {
+			return false, err
+		}
+	}
+	if _, err := io.ReadFull(in, h[:]); err != nil {
+		return false, err
+	}
+	var tail [1]byte
+	if n, _ := in.Read(tail[:]); n != 0 {
+		return false, errWrongPrecalc
+	}
+	if !bytes.Equal(h[:], contentHash.Sum(nil)) {
+		return false, nil
+	}
+	sd.setPrecalc(invLower)
+	sd.sparseMutex.Lock()
+	sd.sparseBasis = sparseBasis
+	sd.sparseMutex.Unlock()
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package kzg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestPrecalcOmegaDomain(t *testing.T) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+	require.False(t, tr.Omega.Equal(tr.ZeroG1))
+	require.NotNil(t, tr.precalc)
+
+	e := suite.G1().Scalar()
+	for m := 0; m < int(tr.D); m += 7 {
+		for j := 0; j < int(tr.D); j += 3 {
+			if m == j {
+				continue
+			}
+			e.Sub(tr.Domain[m], tr.Domain[j])
+			e.Mul(e, tr.invsub(m, j))
+			require.True(t, e.Equal(tr.OneG1))
+		}
+	}
+}
+
+func TestPrecalcFile(t *testing.T) {
+	suite := bn256.NewSuite()
+	dir := t.TempDir()
+	data, err := ioutil.ReadFile("example.setup")
+	require.NoError(t, err)
+	fname := filepath.Join(dir, "example.setup")
+	require.NoError(t, ioutil.WriteFile(fname, data, 0600))
+
+	tr, err := TrustedSetupFromFile(suite, fname)
+	require.NoError(t, err)
+	require.NoError(t, tr.WritePrecalcFile(fname))
+
+	trBack, err := TrustedSetupFromFile(suite, fname)
+	require.NoError(t, err)
+	require.NotNil(t, trBack.sparseBasis)
+	for m := range tr.precalc.ta {
+		require.True(t, tr.precalc.tk[m].Equal(trBack.precalc.tk[m]))
+		require.True(t, tr.sparseBase(m).Equal(trBack.sparseBasis[m]))
+	}
+	rnd := random.New()
+	vect := make([]kyber.Scalar, D)
+	for i := 0; i < D; i += 3 {
+		vect[i] = suite.G1().Scalar().Pick(rnd)
+	}
+	c := trBack.Commit(vect)
+	for _, i := range []int{0, 3, 4, 256} {
+		pi := trBack.Prove(vect, i)
+		require.True(t, pi.Equal(tr.Prove(vect, i)))
+		require.True(t, trBack.Verify(c, pi, valueOrZero(trBack, vect[i]), i))
+	}
+
+	t.Run("another setup", func(t *testing.T) {
+		omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+		other, err := TrustedSetupFromSecretPowers(suite, D, omega, suite.G1().Scalar().Pick(rnd))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(fname, other.Bytes(), 0600))
+		tr1, err := TrustedSetupFromFile(suite, fname)
+		require.NoError(t, err)
+		require.Nil(t, tr1.sparseBasis)
+		require.True(t, tr1.invsub(1, 0).Equal(other.invsub(1, 0)))
+	})
+	t.Run("corrupted", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(fname, data, 0600))
+		precalcData, err := ioutil.ReadFile(PrecalcFileName(fname))
+		require.NoError(t, err)
+		// truncated, oversized and with trailing bytes
+		for _, wrongData := range [][]byte{
+			precalcData[:len(precalcData)-1],
+			precalcData[:100],
+			append(append([]byte{}, precalcData...), 0),
+		} {
+			require.NoError(t, ioutil.WriteFile(PrecalcFileName(fname), wrongData, 0600))
+			tr1, err := TrustedSetupFromFile(suite, fname)
+			require.NoError(t, err)
+			require.True(t, tr1.Prove(vect, 1).Equal(tr.Prove(vect, 1)))
+			require.True(t, tr1.invsub(5, 3).Equal(tr.invsub(5, 3)))
+		}
+
+		// the valid point W<0> in place of W<1> is detected by the content hash and values are recalculated
+		wOffset := 32 + suite.G1().ScalarLen()*D*(D-1)/2
+		wSize := suite.G1().PointLen()
+		corrupted := append([]byte{}, precalcData...)
+		copy(corrupted[wOffset+wSize:], precalcData[wOffset:wOffset+wSize])
+		require.NoError(t, ioutil.WriteFile(PrecalcFileName(fname), corrupted, 0600))
+		tr1, err := TrustedSetupFromFile(suite, fname)
+		require.NoError(t, err)
+		require.True(t, tr1.sparseBase(1).Equal(tr.sparseBase(1)))
+		require.True(t, tr1.Prove(vect, 1).Equal(tr.Prove(vect, 1)))
+	})
+}
+
+func valueOrZero(tr *TrustedSetup, v kyber.Scalar) kyber.Scalar {
+	if v == nil {
+		return tr.ZeroG1
+	}
+	return v
+}
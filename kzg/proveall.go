@@ -0,0 +1,219 @@
+package kzg
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+// Thresholds of the number of not nil elements in the vector, from BenchmarkProveAll for D = 257:
+//   - CommitAll uses the Toeplitz product if the vector has at least proveAllThreshold not nil elements.
+//     For sparser vectors calling sparse-aware Prove for each not nil element is faster
+//   - ProveAll uses the Toeplitz product if the vector has at least proveAllDenseThreshold not nil elements,
+//     otherwise it calls Prove for each of D positions
+//
+// Thresholds are the crossover points measured on one machine (amd64, Intel Xeon, go test -bench BenchmarkProveAll
+// -benchtime 3x), ms per op by the number of not nil elements:
+//
+//	not nil elements          16    64   128   192   208   224   240   257
+//	Toeplitz                 377   730  1113  1556  1228  1275  1341  1384
+//	Prove all positions      191   538   941  1415  1347  1348  1726  1784
+//	Prove not nil positions   14   143   468  1039  1164  1243  1633  1536
+//
+// The measurement is noisy by about 10%, so the thresholds are approximate. On other hardware they may be
+// different: re-run the benchmark before changing them
+const (
+	proveAllThreshold      = 240
+	proveAllDenseThreshold = 208
+)
+
+// ProveAll returns proofs of all D positions of the vector at once.
+// The proof of the position i is pi<i> = sum<j!=i>(vect<j>/(domain<j>-domain<i>)*[l<j>(s)]1) - vect<i>*W<i> + q(domain<i>)*[l<i>(s)]1
+// (see Prove). In both the omega and the natural domain 1/(domain<j>-domain<i>) = scale<i>*c<j-i>,
+// so the first sum for all i is a product of the Toeplitz matrix with the vector of points vect<j>*[l<j>(s)]1.
+// The product is calculated with the Karatsuba-like splitting in about D^1.58 multiplications on the curve
+// instead of D^2 for calling Prove for each position.
+// Note that FK20-like calculation in D*log(D) operations is not possible here: it requires FFT on the
+// multiplicative subgroup of size 2^k >= 2D, while the scalar field of bn256 has only 2^5 roots of unity.
+// In practice the gain is small. Prove multiplies only points of the Lagrange basis, using the precalculated table
+// (additions only), while the Toeplitz product multiplies arbitrary points. For D = 257 and the dense vector ProveAll
+// is about 20% faster than calling Prove for each position. For vectors with less than proveAllDenseThreshold
+// not nil elements it is slower, so then ProveAll calls Prove for each position
+func (sd *TrustedSetup) ProveAll(vect []kyber.Scalar) []kyber.Point {
+	n := 0
+	for _, v := range vect {
+		if v != nil {
+			n++
+		}
+	}
+	if n >= proveAllDenseThreshold {
+		return sd.proveAllToeplitz(vect)
+	}
+	ret := make([]kyber.Point, sd.D)
+	for i := range ret {
+		ret[i] = sd.Prove(vect, i)
+	}
+	return ret
+}
+
+// proveAllToeplitz calculates proofs of all positions with the Toeplitz product (see ProveAll)
+func (sd *TrustedSetup) proveAllToeplitz(vect []kyber.Scalar) []kyber.Point {
+	points := make([]kyber.Point, sd.D)
+	for j, v := range vect {
+		if v != nil {
+			points[j] = sd.Suite.G1().Point().Mul(v, sd.LagrangeBasis[j])
+		}
+	}
+	c, scale := sd.toeplitzForm()
+	y := toeplitzMul(sd.Suite, c, points)
+
+	w := sd.sparseBases()
+	ret := make([]kyber.Point, sd.D)
+	q := sd.Suite.G1().Scalar()
+	for i := range ret {
+		ret[i] = sd.Suite.G1().Point().Null()
+		if y[i] != nil {
+			ret[i].Mul(scale[i], y[i])
+		}
+		sd.qPoly(vect, i, i, vect[i], q)
+		ret[i].Add(ret[i], sd.Suite.G1().Point().Mul(q, sd.LagrangeBasis[i]))
+		if vect[i] != nil {
+			ret[i].Sub(ret[i], sd.Suite.G1().Point().Mul(vect[i], w[i]))
+		}
+	}
+	return ret
+}
+
+// sparseBases returns all W<i> = sum<j!=i>([l<j>(s)]1/(domain<j>-domain<i>)) (see sparseBase).
+// Missing values are calculated at once as the product of the Toeplitz matrix with the Lagrange basis
+func (sd *TrustedSetup) sparseBases() []kyber.Point {
+	sd.sparseMutex.Lock()
+	defer sd.sparseMutex.Unlock()
+
+	if sd.sparseBasis == nil {
+		sd.sparseBasis = make([]kyber.Point, sd.D)
+	}
+	complete := true
+	for _, w := range sd.sparseBasis {
+		if w == nil {
+			complete = false
+			break
+		}
+	}
+	if !complete {
+		c, scale := sd.toeplitzForm()
+		y := toeplitzMul(sd.Suite, c, sd.LagrangeBasis)
+		for i := range sd.sparseBasis {
+			if sd.sparseBasis[i] == nil {
+				sd.sparseBasis[i] = sd.Suite.G1().Point().Mul(scale[i], y[i])
+			}
+		}
+	}
+	ret := make([]kyber.Point, sd.D)
+	copy(ret, sd.sparseBasis)
+	return ret
+}
+
+// toeplitzForm returns c and scale, such that 1/(domain<j>-domain<i>) = scale<i>*c[j-i+D-1] for i != j.
+// c[D-1] = 0.
+// For the omega domain 1/(omega^j-omega^i) = omega^(-i)*1/(omega^(j-i)-1), for the natural domain 1/(j-i)
+func (sd *TrustedSetup) toeplitzForm() ([]kyber.Scalar, []kyber.Scalar) {
+	d := int(sd.D)
+	natural := sd.Omega.Equal(sd.ZeroG1)
+	c := make([]kyber.Scalar, 2*d-1)
+	scale := make([]kyber.Scalar, d)
+	c[d-1] = sd.Suite.G1().Scalar().Zero()
+	for k := 1; k < d; k++ {
+		c[d-1+k] = sd.invsub(k, 0, sd.Suite.G1().Scalar())
+		c[d-1-k] = sd.invsub(0, k, sd.Suite.G1().Scalar())
+		if !natural {
+			c[d-1-k].Mul(c[d-1-k], sd.Domain[k])
+		}
+	}
+	for i := range scale {
+		if natural {
+			scale[i] = sd.OneG1
+		} else {
+			scale[i] = sd.Suite.G1().Scalar().Inv(sd.Domain[i])
+		}
+	}
+	return c, scale
+}
+
+// toeplitzMul returns y<i> = sum<j>(t[j-i+n-1]*x<j>), i, j = 0..n-1, the product of the Toeplitz matrix
+// with the vector of points. len(t) == 2n-1. nil point is equivalent to the zero point, nil in the result means zero.
+// For even n the matrix is split into blocks [[T0, T1], [T2, T0]] and
+// y = (T0*(x0+x1) + (T1-T0)*x1, T0*(x0+x1) + (T2-T0)*x0), i.e. 3 products of half size instead of 4.
+// For odd n the last row and the last column are calculated separately
+func toeplitzMul(suite *bn256.Suite, t []kyber.Scalar, x []kyber.Point) []kyber.Point {
+	n := len(x)
+	ret := make([]kyber.Point, n)
+	allNil := true
+	for _, p := range x {
+		if p != nil {
+			allNil = false
+			break
+		}
+	}
+	if allNil {
+		return ret
+	}
+	if n == 1 {
+		ret[0] = suite.G1().Point().Mul(t[0], x[0])
+		return ret
+	}
+	if n%2 == 1 {
+		copy(ret, toeplitzMul(suite, t[1:2*n-2], x[:n-1]))
+		if x[n-1] != nil {
+			for i := 0; i < n-1; i++ {
+				ret[i] = addPoints(suite, ret[i], suite.G1().Point().Mul(t[2*n-2-i], x[n-1]))
+			}
+		}
+		// last row
+		scalars := make([]kyber.Scalar, n)
+		points := make([]kyber.Point, n)
+		for j := range x {
+			if x[j] != nil {
+				scalars[j], points[j] = t[j], x[j]
+			}
+		}
+		ret[n-1] = multiScalarMul(suite.G1(), points, scalars)
+		return ret
+	}
+	m := n / 2
+	t0 := t[m : 3*m-1]
+	t1 := subScalars(suite, t[2*m:4*m-1], t0)
+	t2 := subScalars(suite, t[0:2*m-1], t0)
+	x01 := make([]kyber.Point, m)
+	for j := range x01 {
+		x01[j] = addPoints(suite, x[j], x[m+j])
+	}
+	p0 := toeplitzMul(suite, t0, x01)
+	p1 := toeplitzMul(suite, t1, x[m:])
+	p2 := toeplitzMul(suite, t2, x[:m])
+	for i := 0; i < m; i++ {
+		ret[i] = addPoints(suite, p0[i], p1[i])
+		ret[m+i] = addPoints(suite, p0[i], p2[i])
+	}
+	return ret
+}
+
+// addPoints returns p1+p2, where nil is the zero point
+func addPoints(suite *bn256.Suite, p1, p2 kyber.Point) kyber.Point {
+	switch {
+	case p1 == nil && p2 == nil:
+		return nil
+	case p1 == nil:
+		return p2.Clone()
+	case p2 == nil:
+		return p1.Clone()
+	}
+	return suite.G1().Point().Add(p1, p2)
+}
+
+func subScalars(suite *bn256.Suite, s1, s2 []kyber.Scalar) []kyber.Scalar {
+	ret := make([]kyber.Scalar, len(s1))
+	for i := range ret {
+		ret[i] = suite.G1().Scalar().Sub(s1[i], s2[i])
+	}
+	return ret
+}
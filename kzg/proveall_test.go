@@ -0,0 +1,120 @@
+package kzg
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestToeplitzMul(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	for _, n := range []int{1, 2, 3, 5, 8, 17} {
+		tm := make([]kyber.Scalar, 2*n-1)
+		for k := range tm {
+			tm[k] = suite.G1().Scalar().Pick(rnd)
+		}
+		x := make([]kyber.Point, n)
+		for j := range x {
+			if j%3 != 1 {
+				x[j] = suite.G1().Point().Pick(rnd)
+			}
+		}
+		y := toeplitzMul(suite, tm, x)
+		for i := range y {
+			expected := suite.G1().Point().Null()
+			for j := range x {
+				if x[j] != nil {
+					expected.Add(expected, suite.G1().Point().Mul(tm[j-i+n-1], x[j]))
+				}
+			}
+			if y[i] == nil {
+				y[i] = suite.G1().Point().Null()
+			}
+			require.True(t, expected.Equal(y[i]))
+		}
+	}
+}
+
+func TestProveAll(t *testing.T) {
+	suite := bn256.NewSuite()
+	trPowers, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+	trNatural, err := TrustedSetupFromSeed(suite, D, []byte("natural domain"))
+	require.NoError(t, err)
+	rnd := random.New()
+
+	for _, tr := range []*TrustedSetup{trPowers, trNatural} {
+		w := tr.sparseBases()
+		tr.sparseBasis = nil
+		for _, i := range []int{0, 1, 100, 256} {
+			require.True(t, w[i].Equal(tr.sparseBase(i)))
+		}
+		for _, step := range []int{1, 10} {
+			vect := make([]kyber.Scalar, D)
+			for i := 0; i < D; i += step {
+				vect[i] = suite.G1().Scalar().Pick(rnd)
+			}
+			c := tr.Commit(vect)
+			pi := tr.ProveAll(vect)
+			piToeplitz := tr.proveAllToeplitz(vect)
+			for _, i := range []int{0, 1, 5, 10, 128, 255, 256} {
+				require.True(t, pi[i].Equal(tr.Prove(vect, i)))
+				require.True(t, piToeplitz[i].Equal(pi[i]))
+			}
+			require.True(t, tr.VerifyVector(vect, c))
+			vect[3] = suite.G1().Scalar().SetInt64(3)
+			require.False(t, tr.VerifyVector(vect, c))
+		}
+	}
+}
+
+// BenchmarkProveAll compares the Toeplitz product with calling Prove for each position on vectors
+// with n not nil elements. Thresholds in proveall.go are derived from it
+
+// benchmarkProveAllVect returns the vector with n not nil elements spread evenly
+func benchmarkProveAllVect(suite *bn256.Suite, n int) []kyber.Scalar {
+	rnd := random.New()
+	vect := make([]kyber.Scalar, D)
+	for k := 0; k < n; k++ {
+		vect[k*D/n] = suite.G1().Scalar().Pick(rnd)
+	}
+	return vect
+}
+
+func BenchmarkProveAll(b *testing.B) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(b, err)
+	tr.sparseBases()
+	for _, n := range []int{16, 64, 128, 192, 208, 224, 240, D} {
+		vect := benchmarkProveAllVect(suite, n)
+		b.Run(fmt.Sprintf("Toeplitz/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tr.proveAllToeplitz(vect)
+			}
+		})
+		// all positions, as in the proof cache of the trie
+		b.Run(fmt.Sprintf("Prove all positions/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for k := range vect {
+					tr.Prove(vect, k)
+				}
+			}
+		})
+		// not nil positions, as in CommitAll
+		b.Run(fmt.Sprintf("Prove not nil positions/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for k := range vect {
+					if vect[k] != nil {
+						tr.Prove(vect, k)
+					}
+				}
+			}
+		})
+	}
+}
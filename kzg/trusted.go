@@ -6,6 +6,8 @@ import (
 	"golang.org/x/crypto/blake2b"
 	"io"
 	"io/ioutil"
+	"os"
+	"sync"
 
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
@@ -26,26 +28,60 @@ type TrustedSetup struct {
 	Omega         kyber.Scalar  // persistent
 	LagrangeBasis []kyber.Point // persistent. TLi = [l<i>(secret)]1
 	Diff2         []kyber.Point // persistent
+	// LagrangeBasis2 is optional, persistent. TL2i = [l<i>(secret)]2. Only needed for multi-index openings
+	LagrangeBasis2 []kyber.Point
+	// BlindingBasis is optional, persistent. TBi = [l<i>(secret)]H, where H is the blinding generator.
+	// Only needed for hiding commitments
+	BlindingBasis []kyber.Point
+	// S2 = [s]2 is persistent. It is needed to verify openings outside the domain.
+	// If the serialized trusted setup does not contain it, it is restored from Diff2<0> = [s-domain<0>]2
+	S2 kyber.Point
+	// PowersOfTau is optional, persistent. Powers of the secret the trusted setup was derived from.
+	// They allow to derive trusted setups of other degrees
+	PowersOfTau *PowersOfTau
 	// auxiliary, precalculated values
 	Domain        []kyber.Scalar // non-persistent. if omega != 0, domain_i =  omega^i, otherwise domain_i = i.
 	AprimeDomainI []kyber.Scalar // A'(i)
-	precalc       *precalculated // only not nil if omega == nil (onl for natural domain)
+	precalc       *precalculated // calculated upon generation or loading of the trusted setup
 	ZeroG1        kyber.Scalar   // aux
 	OneG1         kyber.Scalar   // aux
+	// lazily calculated W<i> = sum<j!=i>([l<j>(s)]1/(domain<j>-domain<i>)), used by sparse-aware Prove
+	sparseBasis []kyber.Point
+	sparseMutex sync.Mutex
+	// lazily calculated update keys U<i,j>, used to update proofs
+	updateKeys      [][]kyber.Point
+	updateKeysMutex sync.Mutex
+	// lazily calculated table for multi-scalar multiplication with the Lagrange basis
+	lagrangeBasisTable *fixedBaseTable
+	lagrangeTableOnce  sync.Once
+	// lazily calculated table for multi-scalar multiplication with the blinding basis
+	blindingBasisTable *fixedBaseTable
+	blindingTableOnce  sync.Once
 }
 
-// used if omega == 0, i.e. for the natural domain
+// precalculated values of the domain, both for omega and natural domain
 type precalculated struct {
-	// 1/(i-m). Array size is 2d-1. To find index for 1/(i-m) is (i-m)+d-1, from 0 to 2d-1. If i==m, index will contain nil
-	invsub []kyber.Scalar
+	invsub [][]kyber.Scalar // invsub[m][j] = 1/(domain<m>-domain<j>). Nil if m == j
 	ta     [][]kyber.Scalar // ta[m][j] = (aprime(m)/aprime(j))(1/(m-j). Nil if m == j
 	tk     []kyber.Scalar   // tk[m] = sum_{j!=m}ta[m][j]
+	// invAprime[m] = 1/A'(domain<m>), barycentric weights
+	invAprime []kyber.Scalar
 }
 
+// tags of optional sections which may follow the mandatory part of the serialized trusted setup
+const (
+	sectionLagrangeBasis2 = byte(1)
+	sectionBlindingBasis  = byte(2)
+	sectionSecretG2       = byte(3)
+	sectionPowersOfTau    = byte(4)
+)
+
 var (
-	errWrongSecret = xerrors.New("wrong secret")
-	errNotROU      = xerrors.New("not a root of unity")
-	errWrongROU    = xerrors.New("wrong root of unity")
+	errUnknownSection = xerrors.New("unknown section of the trusted setup")
+	errWrongSecret    = xerrors.New("wrong secret")
+	errNotROU         = xerrors.New("not a root of unity")
+	errWrongROU       = xerrors.New("wrong root of unity")
+	errWrongSecretG2  = xerrors.New("[s]2 does not match the trusted setup")
 )
 
 func newTrustedSetup(suite *bn256.Suite) *TrustedSetup {
@@ -109,38 +145,91 @@ func TrustedSetupFromSeed(suite *bn256.Suite, d uint16, seed []byte) (*TrustedSe
 
 // TrustedSetupFromBytes unmarshals trusted setup from binary representation
 func TrustedSetupFromBytes(suite *bn256.Suite, data []byte) (*TrustedSetup, error) {
+	ret, err := trustedSetupFromBytesNoPrecalc(suite, data)
+	if err != nil {
+		return nil, err
+	}
+	ret.precalculate()
+	return ret, nil
+}
+
+func trustedSetupFromBytesNoPrecalc(suite *bn256.Suite, data []byte) (*TrustedSetup, error) {
 	ret := newTrustedSetup(suite)
-	if err := ret.read(bytes.NewReader(data)); err != nil {
+	if err := ret.read(data); err != nil {
 		return nil, err
 	}
-	if !ret.Omega.Equal(ret.ZeroG1) {
-		for i := range ret.Domain {
-			powerSimple(ret.Suite, ret.Omega, i, ret.Domain[i])
-			if i > 0 && ret.Domain[i].Equal(ret.OneG1) {
-				return nil, errWrongROU
-			}
-		}
-	} else {
-		for i := range ret.Domain {
-			ret.Domain[i].SetInt64(int64(i))
-		}
+	if err := ret.initDomain(); err != nil {
+		return nil, err
 	}
-	for i := range ret.AprimeDomainI {
-		ret.aprime(i, ret.AprimeDomainI[i])
+	if ret.S2 == nil {
+		ret.S2 = ret.secretG2FromDiff2()
+	} else if !ret.S2.Equal(ret.secretG2FromDiff2()) {
+		return nil, errWrongSecretG2
+	}
+	if ret.BlindingBasis != nil {
+		if err := ret.checkBlindingBasis(); err != nil {
+			return nil, err
+		}
 	}
 	return ret, nil
 }
 
-// TrustedSetupFromFile restores trusted setup from file
+// initDomain calculates the domain from omega and A'(domain<i>)
+func (sd *TrustedSetup) initDomain() error {
+	if err := calcDomain(sd.Suite, sd.Omega, sd.Domain); err != nil {
+		return err
+	}
+	for i := range sd.AprimeDomainI {
+		sd.aprime(i, sd.AprimeDomainI[i])
+	}
+	return nil
+}
+
+// calcDomain calculates domain<i> = omega^i or, if omega == 0, domain<i> = i
+func calcDomain(suite *bn256.Suite, omega kyber.Scalar, domain []kyber.Scalar) error {
+	one := suite.G1().Scalar().One()
+	natural := omega.Equal(suite.G1().Scalar().Zero())
+	for i := range domain {
+		if domain[i] == nil {
+			domain[i] = suite.G1().Scalar()
+		}
+		if natural {
+			domain[i].SetInt64(int64(i))
+			continue
+		}
+		powerSimple(suite, omega, i, domain[i])
+		if i > 0 && domain[i].Equal(one) {
+			return errWrongROU
+		}
+	}
+	return nil
+}
+
+// TrustedSetupFromFile restores trusted setup from file.
+// If the sidecar file with precalculated values (see WritePrecalcFile) exists, belongs
+// to the trusted setup and matches its content hash, precalculated values are read from it. Otherwise,
+// also if the sidecar file can't be read, they are calculated
 func TrustedSetupFromFile(suite *bn256.Suite, fname string) (*TrustedSetup, error) {
 	data, err := ioutil.ReadFile(fname)
 	if err != nil {
 		return nil, err
 	}
-	ret, err := TrustedSetupFromBytes(suite, data)
+	ret, err := trustedSetupFromBytesNoPrecalc(suite, data)
 	if err != nil {
 		return nil, err
 	}
+	precalcData, err := ioutil.ReadFile(PrecalcFileName(fname))
+	if os.IsNotExist(err) {
+		ret.precalculate()
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := ret.readPrecalc(bytes.NewReader(precalcData)); !ok || err != nil {
+		// the sidecar file belongs to another trusted setup or is corrupted
+		ret.precalculate()
+	}
 	return ret, nil
 }
 
@@ -182,6 +271,10 @@ func (sd *TrustedSetup) generatePowers(omega, secret kyber.Scalar) error {
 		e2.Sub(secret, sd.Domain[i])
 		sd.Diff2[i].Mul(e2, nil)
 	}
+	sd.S2 = sd.Suite.G2().Point().Mul(secret, nil)
+	sd.generateLagrangeBasis2(secret)
+	sd.generateBlindingBasis(secret)
+	sd.precalculate()
 	return nil
 }
 
@@ -208,10 +301,22 @@ func (sd *TrustedSetup) generateFromNaturalDomain(secret kyber.Scalar) error {
 		e2.Sub(secret, sd.Domain[i])
 		sd.Diff2[i].Mul(e2, nil)
 	}
+	sd.S2 = sd.Suite.G2().Point().Mul(secret, nil)
+	sd.generateLagrangeBasis2(secret)
+	sd.generateBlindingBasis(secret)
 	sd.precalculate()
 	return nil
 }
 
+// generateLagrangeBasis2 calculates Lagrange basis on G2: [l_i(s)]2
+func (sd *TrustedSetup) generateLagrangeBasis2(secret kyber.Scalar) {
+	sd.LagrangeBasis2 = make([]kyber.Point, sd.D)
+	for i := range sd.LagrangeBasis2 {
+		l := sd.evalLagrangeValue(i, secret)
+		sd.LagrangeBasis2[i] = sd.Suite.G2().Point().Mul(l, nil)
+	}
+}
+
 // evalLagrangeValue calculates li(X) = [prod<j=0,D-1;j!=i>((X-omega^j)/(omega^i-omega^j)]1
 func (sd *TrustedSetup) evalLagrangeValue(i int, v kyber.Scalar) kyber.Scalar {
 	ret := sd.Suite.G1().Scalar().One()
@@ -244,33 +349,11 @@ func (sd *TrustedSetup) aprime(m int, ret kyber.Scalar) kyber.Scalar {
 	return ret
 }
 
-// write marshal
-func (sd *TrustedSetup) write(w io.Writer) error {
-	var tmp2 [2]byte
-	binary.LittleEndian.PutUint16(tmp2[:], sd.D)
-	if _, err := w.Write(tmp2[:]); err != nil {
-		return err
-	}
-	if _, err := sd.Omega.MarshalTo(w); err != nil {
-		return err
-	}
-	for i := range sd.LagrangeBasis {
-		if _, err := sd.LagrangeBasis[i].MarshalTo(w); err != nil {
-			return err
-		}
-	}
-	for i := range sd.Diff2 {
-		if _, err := sd.Diff2[i].MarshalTo(w); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// read unmarshal
-func (sd *TrustedSetup) read(r io.Reader) error {
+// readLegacy unmarshals the trusted setup from the format before version 2:
+// D (uint16), omega, Lagrange basis, Diff2 and optional sections, each starting with the tag
+func (sd *TrustedSetup) readLegacy(r io.Reader) error {
 	var tmp2 [2]byte
-	if _, err := r.Read(tmp2[:]); err != nil {
+	if _, err := io.ReadFull(r, tmp2[:]); err != nil {
 		return err
 	}
 
@@ -292,7 +375,41 @@ func (sd *TrustedSetup) read(r io.Reader) error {
 			return err
 		}
 	}
-	return nil
+	// optional sections, each starts with the tag
+	var tag [1]byte
+	for {
+		if _, err := io.ReadFull(r, tag[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch tag[0] {
+		case sectionLagrangeBasis2:
+			sd.LagrangeBasis2 = make([]kyber.Point, sd.D)
+			for i := range sd.LagrangeBasis2 {
+				sd.LagrangeBasis2[i] = sd.Suite.G2().Point()
+				if _, err := sd.LagrangeBasis2[i].UnmarshalFrom(r); err != nil {
+					return err
+				}
+			}
+		case sectionBlindingBasis:
+			sd.BlindingBasis = make([]kyber.Point, sd.D)
+			for i := range sd.BlindingBasis {
+				sd.BlindingBasis[i] = sd.Suite.G1().Point()
+				if _, err := sd.BlindingBasis[i].UnmarshalFrom(r); err != nil {
+					return err
+				}
+			}
+		case sectionSecretG2:
+			sd.S2 = sd.Suite.G2().Point()
+			if _, err := sd.S2.UnmarshalFrom(r); err != nil {
+				return err
+			}
+		default:
+			return errUnknownSection
+		}
+	}
 }
 
 func (sd *TrustedSetup) ta(m, j int, ret kyber.Scalar) kyber.Scalar {
@@ -322,36 +439,47 @@ func (sd *TrustedSetup) tk(m int, ret kyber.Scalar) kyber.Scalar {
 	return ret
 }
 
+// precalculate calculates invsub, ta and tk tables of the domain
 func (sd *TrustedSetup) precalculate() {
+	// differences domain<m>-domain<j> for all m > j are inverted with one field inversion
+	diffs := make([]kyber.Scalar, 0, int(sd.D)*(int(sd.D)-1)/2)
+	for m := 1; m < int(sd.D); m++ {
+		for j := 0; j < m; j++ {
+			diffs = append(diffs, sd.Suite.G1().Scalar().Sub(sd.Domain[m], sd.Domain[j]))
+		}
+	}
+	sd.setPrecalc(batchInverse(sd.Suite, diffs))
+}
+
+// setPrecalc sets up precalculated tables from inverses of domain<m>-domain<j> for all m > j,
+// listed in the order m = 1..D-1, j = 0..m-1
+func (sd *TrustedSetup) setPrecalc(invLower []kyber.Scalar) {
 	sd.precalc = &precalculated{
-		invsub: make([]kyber.Scalar, sd.D*2-1),
+		invsub: make([][]kyber.Scalar, sd.D),
 		ta:     make([][]kyber.Scalar, sd.D),
 		tk:     make([]kyber.Scalar, sd.D),
 	}
-	for i := range sd.precalc.ta {
-		sd.precalc.ta[i] = make([]kyber.Scalar, sd.D)
+	for m := range sd.precalc.invsub {
+		sd.precalc.invsub[m] = make([]kyber.Scalar, sd.D)
+		sd.precalc.ta[m] = make([]kyber.Scalar, sd.D)
 	}
-	tj := sd.Suite.G1().Scalar()
-	for j := 0; j < int(sd.D); j++ {
-		tj.SetInt64(int64(j))
-		for m := 0; m < int(sd.D); m++ {
-			if j == m {
-				continue
-			}
-			idx := int(sd.D) - 1 + m - j
-			sd.precalc.invsub[idx] = sd.Suite.G1().Scalar().SetInt64(int64(m))
-			sd.precalc.invsub[idx].Sub(sd.precalc.invsub[idx], tj)
-			sd.precalc.invsub[idx].Inv(sd.precalc.invsub[idx])
+	k := 0
+	for m := 1; m < int(sd.D); m++ {
+		for j := 0; j < m; j++ {
+			sd.precalc.invsub[m][j] = invLower[k]
+			sd.precalc.invsub[j][m] = sd.Suite.G1().Scalar().Neg(invLower[k])
+			k++
 		}
 	}
-	for j := range sd.precalc.ta {
-		for m := range sd.precalc.ta[j] {
+	invAprime := batchInverse(sd.Suite, sd.AprimeDomainI)
+	sd.precalc.invAprime = invAprime
+	for m := range sd.precalc.ta {
+		for j := range sd.precalc.ta[m] {
 			if m == j {
 				continue
 			}
-			sd.precalc.ta[m][j] = sd.Suite.G1().Scalar().Set(sd.AprimeDomainI[m])
-			sd.precalc.ta[m][j].Div(sd.precalc.ta[m][j], sd.AprimeDomainI[j])
-			sd.precalc.ta[m][j].Mul(sd.precalc.ta[m][j], sd.invsub(m, j))
+			sd.precalc.ta[m][j] = sd.Suite.G1().Scalar().Mul(sd.AprimeDomainI[m], invAprime[j])
+			sd.precalc.ta[m][j].Mul(sd.precalc.ta[m][j], sd.precalc.invsub[m][j])
 		}
 	}
 	for m := range sd.precalc.tk {
@@ -377,9 +505,8 @@ func (sd *TrustedSetup) invsub(m, j int, set ...kyber.Scalar) kyber.Scalar {
 		ret.Inv(ret)
 		return ret
 	}
-	idx := int(sd.D) - 1 + m - j
 	if len(set) > 0 {
-		set[0].Set(sd.precalc.invsub[idx])
+		return set[0].Set(sd.precalc.invsub[m][j])
 	}
-	return sd.precalc.invsub[idx]
+	return sd.precalc.invsub[m][j]
 }
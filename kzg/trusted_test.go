@@ -221,3 +221,87 @@ func TestValidate2Load(t *testing.T) {
 		}
 	}
 }
+
+func TestBatchVerify(t *testing.T) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	vect := make([]kyber.Scalar, D)
+	for i := range vect {
+		if i%3 == 0 {
+			vect[i] = tr.Suite.G1().Scalar().SetInt64(int64(i))
+		}
+	}
+	c := tr.Commit(vect)
+	items := make([]Opening, 0)
+	for _, i := range []int{0, 1, 3, 100, 255, 256} {
+		items = append(items, Opening{
+			C:     c,
+			Pi:    tr.Prove(vect, i),
+			Value: vect[i],
+			Index: i,
+		})
+	}
+	vect1 := make([]kyber.Scalar, D)
+	vect1[5] = tr.Suite.G1().Scalar().SetInt64(5)
+	c1 := tr.Commit(vect1)
+	items = append(items, Opening{
+		C:     c1,
+		Pi:    tr.Prove(vect1, 5),
+		Value: vect1[5],
+		Index: 5,
+	})
+	require.True(t, tr.BatchVerify(items))
+	require.True(t, tr.BatchVerify(items[:1]))
+	require.True(t, tr.BatchVerify(nil))
+
+	items[2].Value = tr.Suite.G1().Scalar().SetInt64(3)
+	require.True(t, tr.BatchVerify(items))
+	items[2].Value = tr.Suite.G1().Scalar().SetInt64(4)
+	require.False(t, tr.BatchVerify(items))
+	items[2].Value = vect[3]
+	items[3].Index = 101
+	require.False(t, tr.BatchVerify(items))
+	items[3].Index = 100
+	items[6].C = c
+	require.False(t, tr.BatchVerify(items))
+}
+
+// proveDense calculates the proof from quotient polynomial in evaluation form over all domain points
+func proveDense(tr *TrustedSetup, vect []kyber.Scalar, i int) kyber.Point {
+	q := make([]kyber.Scalar, tr.D)
+	for j := range q {
+		q[j] = tr.Suite.G1().Scalar()
+		tr.qPoly(vect, i, j, vect[i], q[j])
+	}
+	return tr.Commit(q)
+}
+
+func TestProveSparse(t *testing.T) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+	rnd := random.New()
+
+	vect := make([]kyber.Scalar, D)
+	vect[256] = tr.Suite.G1().Scalar().Pick(rnd)
+	vect[3] = tr.Suite.G1().Scalar().Pick(rnd)
+	vect[4] = tr.Suite.G1().Scalar().Zero()
+	c := tr.Commit(vect)
+	for _, i := range []int{0, 3, 4, 5, 256} {
+		pi := tr.Prove(vect, i)
+		require.True(t, pi.Equal(proveDense(tr, vect, i)))
+		v := vect[i]
+		if v == nil {
+			v = tr.ZeroG1
+		}
+		require.True(t, tr.Verify(c, pi, v, i))
+	}
+	for i := range vect {
+		vect[i] = tr.Suite.G1().Scalar().Pick(rnd)
+	}
+	for _, i := range []int{0, 100, 256} {
+		require.True(t, tr.Prove(vect, i).Equal(proveDense(tr, vect, i)))
+	}
+}
@@ -0,0 +1,52 @@
+package kzg
+
+import (
+	"go.dedis.ch/kyber/v3"
+)
+
+// UpdateKey returns the update key U<i,j>. When vect<j> changes by delta, the commitment to the vector
+// changes by delta*[l<j>(s)]1 and the proof of vect<i> changes by delta*U<i,j>, where
+// U<i,j> = [l<j>(s)]1/(domain<j>-domain<i>) + ta(i,j)*[l<i>(s)]1 for j != i and
+// U<i,i> = -W<i> - tk(i)*[l<i>(s)]1.
+// Keys are calculated upon first request
+func (sd *TrustedSetup) UpdateKey(i, j int) kyber.Point {
+	sd.updateKeysMutex.Lock()
+	if sd.updateKeys == nil {
+		sd.updateKeys = make([][]kyber.Point, sd.D)
+	}
+	if sd.updateKeys[i] == nil {
+		sd.updateKeys[i] = make([]kyber.Point, sd.D)
+	}
+	ret := sd.updateKeys[i][j]
+	sd.updateKeysMutex.Unlock()
+	if ret != nil {
+		return ret
+	}
+
+	t := sd.Suite.G1().Scalar()
+	if i != j {
+		ret = sd.Suite.G1().Point().Mul(sd.invsub(j, i, t), sd.LagrangeBasis[j])
+		ret.Add(ret, sd.Suite.G1().Point().Mul(sd.ta(i, j, t), sd.LagrangeBasis[i]))
+	} else {
+		ret = sd.Suite.G1().Point().Mul(sd.tk(i, t), sd.LagrangeBasis[i])
+		ret.Add(ret, sd.sparseBase(i))
+		ret.Neg(ret)
+	}
+	sd.updateKeysMutex.Lock()
+	sd.updateKeys[i][j] = ret
+	sd.updateKeysMutex.Unlock()
+	return ret
+}
+
+// UpdateCommitment returns commitment to the vector after vect<j> has been changed by delta
+func (sd *TrustedSetup) UpdateCommitment(c kyber.Point, j int, delta kyber.Scalar) kyber.Point {
+	ret := sd.Suite.G1().Point().Mul(delta, sd.LagrangeBasis[j])
+	return ret.Add(ret, c)
+}
+
+// UpdateProof returns proof of vect<i> after vect<j> has been changed by delta.
+// It takes one multiplication on the curve instead of proving from scratch
+func (sd *TrustedSetup) UpdateProof(pi kyber.Point, i, j int, delta kyber.Scalar) kyber.Point {
+	ret := sd.Suite.G1().Point().Mul(delta, sd.UpdateKey(i, j))
+	return ret.Add(ret, pi)
+}
@@ -0,0 +1,44 @@
+package kzg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestUpdateProof(t *testing.T) {
+	suite := bn256.NewSuite()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+	rnd := random.New()
+
+	vect := make([]kyber.Scalar, D)
+	for i := 0; i < D; i += 4 {
+		vect[i] = suite.G1().Scalar().Pick(rnd)
+	}
+	indices := []int{0, 1, 8, 256}
+	c := tr.Commit(vect)
+	pi := make([]kyber.Point, len(indices))
+	for k, i := range indices {
+		pi[k] = tr.Prove(vect, i)
+	}
+	// changes of the existing element, of the nil element and of the proved elements themselves
+	for _, j := range []int{4, 5, 0, 1, 256} {
+		delta := suite.G1().Scalar().Pick(rnd)
+		if vect[j] == nil {
+			vect[j] = suite.G1().Scalar().Set(delta)
+		} else {
+			vect[j].Add(vect[j], delta)
+		}
+		c = tr.UpdateCommitment(c, j, delta)
+		require.True(t, c.Equal(tr.Commit(vect)))
+		for k, i := range indices {
+			pi[k] = tr.UpdateProof(pi[k], i, j, delta)
+			require.True(t, pi[k].Equal(tr.Prove(vect, i)))
+			require.True(t, tr.Verify(c, pi[k], valueOrZero(tr, vect[i]), i))
+		}
+	}
+}
@@ -91,3 +91,20 @@ func GenRootOfUnityQuasiPrimitive(suite *bn256.Suite, d uint16) (kyber.Scalar, [
 	}
 	return rou, retPowers
 }
+
+// batchInverse returns inverses of scalars with one field inversion (Montgomery's trick).
+// All scalars must be non-zero
+func batchInverse(suite *bn256.Suite, scalars []kyber.Scalar) []kyber.Scalar {
+	ret := make([]kyber.Scalar, len(scalars))
+	acc := suite.G1().Scalar().One()
+	for i := range scalars {
+		ret[i] = suite.G1().Scalar().Set(acc)
+		acc.Mul(acc, scalars[i])
+	}
+	acc.Inv(acc)
+	for i := len(scalars) - 1; i >= 0; i-- {
+		ret[i].Mul(ret[i], acc)
+		acc.Mul(acc, scalars[i])
+	}
+	return ret
+}
@@ -0,0 +1,159 @@
+package kzg
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/random"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errWrongSize    = xerrors.New("wrong size of the trusted setup")
+	errDegenerate   = xerrors.New("degenerate point in the trusted setup")
+	errInconsistent = xerrors.New("inconsistent trusted setup")
+)
+
+// Validate checks if all parts of the trusted setup are derived from the same secret s, which is not in the domain:
+//   - no point is zero
+//   - sum<i>([l<i>(s)]1) == [1]1, because sum<i>(l<i>(X)) == 1
+//   - Diff2<i> == [s]2-[domain<i>]2 for all i
+//   - the Lagrange basis opens the random polynomial f at the random point z: e(pi, [s-z]2) == e(C-[f(z)]1, [1]2),
+//     where C and pi are calculated with the Lagrange basis. It holds for all f only if the basis is [l<i>(s)]1
+//     with the same s as in [s]2. One random check is enough with overwhelming probability
+//   - the same for the optional blinding basis on the blinding generator
+//   - e(sum<i>(rho<i>*[l<i>(s)]1), [1]2) == e([1]1, sum<i>(rho<i>*[l<i>(s)]2)) for the optional LagrangeBasis2 and random rho<i>
+//   - the optional powers of tau are consecutive powers of s with G2<1> == [s]2 and they derive the stored bases
+//     (see checkPowersOfTau)
+func (sd *TrustedSetup) Validate() error {
+	d := int(sd.D)
+	if d == 0 || len(sd.LagrangeBasis) != d || len(sd.Diff2) != d || len(sd.Domain) != d {
+		return errWrongSize
+	}
+	if sd.LagrangeBasis2 != nil && len(sd.LagrangeBasis2) != d {
+		return errWrongSize
+	}
+	if sd.BlindingBasis != nil && len(sd.BlindingBasis) != d {
+		return errWrongSize
+	}
+	for _, points := range [][]kyber.Point{sd.LagrangeBasis, sd.Diff2, sd.LagrangeBasis2, sd.BlindingBasis} {
+		for _, p := range points {
+			if p.Equal(p.Clone().Null()) {
+				return errDegenerate
+			}
+		}
+	}
+	sum := sd.Suite.G1().Point().Null()
+	for _, p := range sd.LagrangeBasis {
+		sum.Add(sum, p)
+	}
+	if !sum.Equal(sd.Suite.G1().Point().Base()) {
+		return xerrors.Errorf("Lagrange basis does not sum up to the generator: %w", errInconsistent)
+	}
+	s2 := sd.secretG2()
+	e := sd.Suite.G2().Point()
+	for i := range sd.Diff2 {
+		e.Mul(sd.Domain[i], nil)
+		if !sd.Diff2[i].Equal(e.Sub(s2, e)) {
+			return xerrors.Errorf("Diff2<%d> does not fit the domain: %w", i, errInconsistent)
+		}
+	}
+	if !sd.checkRandomOpening(sd.LagrangeBasis, sd.Suite.G1().Point().Base()) {
+		return xerrors.Errorf("Lagrange basis does not match [s]2: %w", errInconsistent)
+	}
+	if sd.BlindingBasis != nil {
+		if err := sd.checkBlindingBasis(); err != nil {
+			return err
+		}
+		if !sd.checkRandomOpening(sd.BlindingBasis, blindingGenerator) {
+			return xerrors.Errorf("blinding basis does not match [s]2: %w", errInconsistent)
+		}
+	}
+	if sd.LagrangeBasis2 != nil {
+		rnd := random.New()
+		rho := make([]kyber.Scalar, d)
+		for i := range rho {
+			rho[i] = sd.Suite.G1().Scalar().Pick(rnd)
+		}
+		p1 := sd.Suite.Pair(multiScalarMul(sd.Suite.G1(), sd.LagrangeBasis, rho), sd.Suite.G2().Point().Base())
+		p2 := sd.Suite.Pair(sd.Suite.G1().Point().Base(), multiScalarMul(sd.Suite.G2(), sd.LagrangeBasis2, rho))
+		if !p1.Equal(p2) {
+			return xerrors.Errorf("LagrangeBasis2 does not match the Lagrange basis: %w", errInconsistent)
+		}
+	}
+	if sd.PowersOfTau != nil {
+		if err := sd.checkPowersOfTau(); err != nil {
+			return xerrors.Errorf("powers of tau: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkPowersOfTau checks that powers of tau are consecutive powers of the secret of the trusted setup
+// and that each basis derived from them is the stored one. Instead of deriving all D points of the basis,
+// the random combination of it is compared: sum<i>(rho<i>*basis<i>) == sum<k>(f<k>*powers<k>),
+// where f<k> = sum<i>(rho<i>*c<i,k>) are coefficients of the polynomial with values rho<i> on the domain
+func (sd *TrustedSetup) checkPowersOfTau() error {
+	pt := sd.PowersOfTau
+	if err := pt.check(); err != nil {
+		return err
+	}
+	if len(pt.G1) < int(sd.D) {
+		return errNotEnoughPowers
+	}
+	if !pt.G2[1].Equal(sd.secretG2()) {
+		return xerrors.Errorf("[s]2 does not match the trusted setup: %w", errInconsistent)
+	}
+	if err := pt.verifyPowers(); err != nil {
+		return err
+	}
+	rnd := random.New()
+	rho := make([]kyber.Scalar, sd.D)
+	for i := range rho {
+		rho[i] = sd.Suite.G1().Scalar().Pick(rnd)
+	}
+	f := make([]kyber.Scalar, sd.D)
+	for k := range f {
+		f[k] = sd.Suite.G1().Scalar().Zero()
+	}
+	t := sd.Suite.G1().Scalar()
+	for i, c := range sd.lagrangeCoefficients() {
+		for k := range f {
+			f[k].Add(f[k], t.Mul(rho[i], c[k]))
+		}
+	}
+	checks := []struct {
+		name   string
+		group  kyber.Group
+		basis  []kyber.Point
+		powers []kyber.Point
+	}{
+		{"Lagrange basis", sd.Suite.G1(), sd.LagrangeBasis, pt.G1},
+		{"LagrangeBasis2", sd.Suite.G2(), sd.LagrangeBasis2, pt.G2},
+		{"blinding basis", sd.Suite.G1(), sd.BlindingBasis, pt.H},
+	}
+	for _, c := range checks {
+		if c.basis == nil || len(c.powers) < int(sd.D) {
+			continue
+		}
+		if !multiScalarMul(c.group, c.basis, rho).Equal(multiScalarMul(c.group, c.powers[:sd.D], f)) {
+			return xerrors.Errorf("%s is not derived from the powers: %w", c.name, errInconsistent)
+		}
+	}
+	return nil
+}
+
+// checkRandomOpening commits to the random vector with the basis [l<i>(s)]g and checks the opening at the random point
+func (sd *TrustedSetup) checkRandomOpening(basis []kyber.Point, g kyber.Point) bool {
+	rnd := random.New()
+	vect := make([]kyber.Scalar, sd.D)
+	for i := range vect {
+		vect[i] = sd.Suite.G1().Scalar().Pick(rnd)
+	}
+	z := sd.Suite.G1().Scalar().Pick(rnd)
+	for sd.domainIndex(z) >= 0 {
+		z.Pick(rnd)
+	}
+	y := sd.evaluateAt(vect, z, sd.invSubAt(z))
+	c := multiScalarMul(sd.Suite.G1(), basis, vect)
+	pi := multiScalarMul(sd.Suite.G1(), basis, sd.quotientAt(vect, z, y))
+	return sd.verifierKey().verifyAt(c.Sub(c, sd.Suite.G1().Point().Mul(y, g)), pi, z)
+}
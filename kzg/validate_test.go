@@ -0,0 +1,107 @@
+package kzg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestValidate(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	omega, _ := GenRootOfUnityQuasiPrimitive(suite, D)
+	tr, err := TrustedSetupFromSecretPowers(suite, D, omega, suite.G1().Scalar().Pick(rnd))
+	require.NoError(t, err)
+	require.NoError(t, tr.Validate())
+	other, err := TrustedSetupFromSecretPowers(suite, D, omega, suite.G1().Scalar().Pick(rnd))
+	require.NoError(t, err)
+
+	t.Run("file", func(t *testing.T) {
+		trFile, err := TrustedSetupFromFile(suite, "example.setup")
+		require.NoError(t, err)
+		require.NoError(t, trFile.Validate())
+	})
+	t.Run("natural domain", func(t *testing.T) {
+		trNatural, err := TrustedSetupFromSeed(suite, 16, []byte("validate"))
+		require.NoError(t, err)
+		require.NoError(t, trNatural.Validate())
+	})
+	wrong := func() *TrustedSetup {
+		ret, err := TrustedSetupFromBytes(suite, tr.Bytes())
+		require.NoError(t, err)
+		return ret
+	}
+	t.Run("swapped Lagrange basis", func(t *testing.T) {
+		w := wrong()
+		w.LagrangeBasis[3], w.LagrangeBasis[4] = w.LagrangeBasis[4], w.LagrangeBasis[3]
+		require.Error(t, w.Validate())
+	})
+	t.Run("Lagrange basis of another secret", func(t *testing.T) {
+		w := wrong()
+		w.LagrangeBasis = other.LagrangeBasis
+		require.Error(t, w.Validate())
+	})
+	t.Run("Diff2 of another secret", func(t *testing.T) {
+		w := wrong()
+		w.Diff2 = other.Diff2
+		require.Error(t, w.Validate())
+		w.S2 = other.S2
+		require.Error(t, w.Validate())
+	})
+	t.Run("wrong Diff2", func(t *testing.T) {
+		w := wrong()
+		w.Diff2[5] = suite.G2().Point().Pick(rnd)
+		require.Error(t, w.Validate())
+	})
+	t.Run("degenerate", func(t *testing.T) {
+		w := wrong()
+		w.LagrangeBasis[0] = suite.G1().Point().Null()
+		require.Error(t, w.Validate())
+	})
+	t.Run("blinding basis", func(t *testing.T) {
+		w := wrong()
+		w.BlindingBasis[0], w.BlindingBasis[1] = w.BlindingBasis[1], w.BlindingBasis[0]
+		require.Error(t, w.Validate())
+		w.BlindingBasis = other.BlindingBasis
+		require.Error(t, w.Validate())
+	})
+	t.Run("LagrangeBasis2", func(t *testing.T) {
+		w := wrong()
+		w.LagrangeBasis2 = other.LagrangeBasis2
+		require.Error(t, w.Validate())
+	})
+	t.Run("powers of tau", func(t *testing.T) {
+		pt, err := PowersOfTauFromSecret(suite, D, D, suite.G1().Scalar().Pick(rnd))
+		require.NoError(t, err)
+		trPowers, err := TrustedSetupFromPowersOfTau(pt, D, omega)
+		require.NoError(t, err)
+		trPowers.PowersOfTau = pt
+		require.NoError(t, trPowers.Validate())
+		data := trPowers.Bytes()
+
+		wrongPowers := func() *TrustedSetup {
+			ret, err := TrustedSetupFromBytes(suite, data)
+			require.NoError(t, err)
+			require.NoError(t, ret.Validate())
+			return ret
+		}
+		w := wrongPowers()
+		w.PowersOfTau.G1[5] = suite.G1().Point().Pick(rnd)
+		require.Error(t, w.Validate())
+		w = wrongPowers()
+		w.PowersOfTau.G2[7] = suite.G2().Point().Pick(rnd)
+		require.Error(t, w.Validate())
+		w = wrongPowers()
+		w.PowersOfTau.H[3], w.PowersOfTau.H[4] = w.PowersOfTau.H[4], w.PowersOfTau.H[3]
+		require.Error(t, w.Validate())
+		w = wrongPowers()
+		w.PowersOfTau, err = PowersOfTauFromSecret(suite, D, D, suite.G1().Scalar().Pick(rnd))
+		require.NoError(t, err)
+		require.Error(t, w.Validate())
+		w = wrongPowers()
+		w.PowersOfTau.G1 = w.PowersOfTau.G1[:D-1]
+		require.Error(t, w.Validate())
+	})
+}
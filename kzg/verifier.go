@@ -0,0 +1,244 @@
+package kzg
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+	"golang.org/x/crypto/blake2b"
+)
+
+// VerifierKey is the part of the trusted setup needed to verify proofs: the domain and G2 points.
+// It does not contain the Lagrange basis and precalculated values, so it is smaller
+// than the trusted setup and cheap to load. It is exported from the trusted setup with TrustedSetup.VerifierKey
+// The serialized verifier key has the same header as the trusted setup in format version 2 (see format.go),
+// but with the magic "KZGV", followed by Diff2, [s]2 and the content hash
+type VerifierKey struct {
+	Suite  *bn256.Suite
+	D      uint16
+	Omega  kyber.Scalar   // persistent
+	Diff2  []kyber.Point  // persistent. [s-domain<i>]2
+	S2     kyber.Point    // persistent. [s]2
+	Domain []kyber.Scalar // non-persistent. Calculated from omega as in the trusted setup
+}
+
+var verifierKeyMagic = []byte("KZGV")
+
+// VerifierKey returns the verifier key of the trusted setup
+func (sd *TrustedSetup) VerifierKey() *VerifierKey {
+	ret := &VerifierKey{
+		Suite:  sd.Suite,
+		D:      sd.D,
+		Omega:  sd.Omega.Clone(),
+		Diff2:  make([]kyber.Point, sd.D),
+		S2:     sd.secretG2(),
+		Domain: make([]kyber.Scalar, sd.D),
+	}
+	for i := range ret.Diff2 {
+		ret.Diff2[i] = sd.Diff2[i].Clone()
+		ret.Domain[i] = sd.Domain[i].Clone()
+	}
+	return ret
+}
+
+// verifierKey returns the verifier key, which shares data with the trusted setup
+func (sd *TrustedSetup) verifierKey() *VerifierKey {
+	return &VerifierKey{
+		Suite:  sd.Suite,
+		D:      sd.D,
+		Omega:  sd.Omega,
+		Diff2:  sd.Diff2,
+		S2:     sd.secretG2(),
+		Domain: sd.Domain,
+	}
+}
+
+// VerifierKeyFromBytes unmarshals the verifier key
+func VerifierKeyFromBytes(suite *bn256.Suite, data []byte) (*VerifierKey, error) {
+	content, err := checkContentHash(data, verifierKeyMagic)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(content[len(verifierKeyMagic):])
+	d, omega, err := readHeader(suite, r)
+	if err != nil {
+		return nil, err
+	}
+	ret := &VerifierKey{
+		Suite:  suite,
+		D:      d,
+		Omega:  omega,
+		Diff2:  make([]kyber.Point, d),
+		S2:     suite.G2().Point(),
+		Domain: make([]kyber.Scalar, d),
+	}
+	for i := range ret.Diff2 {
+		ret.Diff2[i] = suite.G2().Point()
+	}
+	if err = readPoints(r, ret.Diff2); err != nil {
+		return nil, err
+	}
+	if _, err = ret.S2.UnmarshalFrom(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errWrongFormat
+	}
+	if err = calcDomain(suite, omega, ret.Domain); err != nil {
+		return nil, err
+	}
+	// Diff2 must fit [s]2 and the domain
+	e := suite.G2().Point()
+	for i := range ret.Diff2 {
+		e.Mul(ret.Domain[i], nil)
+		if !ret.Diff2[i].Equal(e.Sub(ret.S2, e)) {
+			return nil, errInconsistent
+		}
+	}
+	return ret, nil
+}
+
+// VerifierKeyFromFile reads the verifier key from file
+func VerifierKeyFromFile(suite *bn256.Suite, fname string) (*VerifierKey, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return VerifierKeyFromBytes(suite, data)
+}
+
+// Bytes marshals the verifier key
+func (vk *VerifierKey) Bytes() []byte {
+	var buf bytes.Buffer
+	if err := vk.write(&buf); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func (vk *VerifierKey) write(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.Write(verifierKeyMagic)
+	if err := writeHeader(&buf, vk.D, vk.Omega); err != nil {
+		return err
+	}
+	if err := writePoints(&buf, vk.Diff2); err != nil {
+		return err
+	}
+	if _, err := vk.S2.MarshalTo(&buf); err != nil {
+		return err
+	}
+	h := blake2b.Sum256(buf.Bytes())
+	buf.Write(h[:])
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Verify verifies KZG proof that polynomial f committed with C has f(domain<atIndex>) = v
+// It checks e(pi, [s-domain<atIndex>]2) * e(-(C-[v]1), [1]2) == 1 with two Miller loops and one final exponentiation.
+// kyber's bn256 does not allow to precompute line functions of the fixed G2 points, so each check runs the full
+// Miller loops, including the G2 arithmetic for points which are the same in every check.
+// To verify many openings, use BatchVerify or VerifyAggregated, which take one check per batch
+func (vk *VerifierKey) Verify(c, pi kyber.Point, v kyber.Scalar, atIndex int) bool {
+	if atIndex < 0 || atIndex >= int(vk.D) {
+		return false
+	}
+	e := vk.Suite.G1().Point().Mul(v, nil)
+	e.Sub(e, c)
+	return vk.pairingProductIsOne(
+		[]kyber.Point{pi, e},
+		[]kyber.Point{vk.Diff2[atIndex], vk.Suite.G2().Point().Base()},
+	)
+}
+
+// BatchVerify verifies many openings at once (see BatchVerify)
+func (vk *VerifierKey) BatchVerify(items []Opening) bool {
+	return BatchVerify(vk, items)
+}
+
+// BatchVerify verifies many openings at once with the verifier key.
+// Each opening satisfies e(pi, [s-domain<i>]2) == e(C-[v]1, [1]2), i.e. e(pi, [s]2) == e(C-[v]1+domain<i>*pi, [1]2)
+// Checks are combined with random scalars rho<k> into one pairing product check:
+// e(sum<k>(rho<k>*pi<k>), [s]2) == e(sum<k>(rho<k>*(C<k>-[v<k>]1+domain<i<k>>*pi<k>)), [1]2)
+// Value == nil is equivalent to 0
+func BatchVerify(vk *VerifierKey, items []Opening) bool {
+	if len(items) == 0 {
+		return true
+	}
+	rnd := random.New()
+	sumV := vk.Suite.G1().Scalar().Zero()
+	s := vk.Suite.G1().Scalar()
+	pis := make([]kyber.Point, len(items))
+	rhos := make([]kyber.Scalar, len(items))
+	// points and scalars of sum<k>(rho<k>*(C<k>+domain<i<k>>*pi<k>))
+	points := make([]kyber.Point, 0, 2*len(items))
+	scalars := make([]kyber.Scalar, 0, 2*len(items))
+	for k, it := range items {
+		if it.Index < 0 || it.Index >= int(vk.D) {
+			return false
+		}
+		rhos[k] = vk.Suite.G1().Scalar().Pick(rnd)
+		pis[k] = it.Pi
+		if it.Value != nil {
+			sumV.Add(sumV, s.Mul(rhos[k], it.Value))
+		}
+		points = append(points, it.C, it.Pi)
+		scalars = append(scalars, rhos[k], vk.Suite.G1().Scalar().Mul(rhos[k], vk.Domain[it.Index]))
+	}
+	sumPi := multiScalarMul(vk.Suite.G1(), pis, rhos)
+	sumC := multiScalarMul(vk.Suite.G1(), points, scalars)
+	sumC.Sub(vk.Suite.G1().Point().Mul(sumV, nil), sumC)
+	return vk.pairingProductIsOne(
+		[]kyber.Point{sumPi, sumC},
+		[]kyber.Point{vk.S2, vk.Suite.G2().Point().Base()},
+	)
+}
+
+// VerifyAt verifies proof pi that polynomial committed with c has value y at z
+func (vk *VerifierKey) VerifyAt(c, pi kyber.Point, z, y kyber.Scalar) bool {
+	e := vk.Suite.G1().Point().Mul(y, nil)
+	return vk.verifyAt(e.Sub(c, e), pi, z)
+}
+
+// verifyAt checks e(pi, [s-t]2) * e(-c, [1]2) == 1, i.e. that pi is a proof of polynomial committed with c having 0 at t
+func (vk *VerifierKey) verifyAt(c, pi kyber.Point, t kyber.Scalar) bool {
+	st := vk.Suite.G2().Point().Mul(t, nil)
+	st.Sub(vk.S2, st)
+	return vk.pairingProductIsOne(
+		[]kyber.Point{pi, vk.Suite.G1().Point().Neg(c)},
+		[]kyber.Point{st, vk.Suite.G2().Point().Base()},
+	)
+}
+
+// millerLoop is the part of the GT point API of kyber's bn256 which splits the pairing into
+// the Miller loop and the final exponentiation
+type millerLoop interface {
+	Miller(p1, p2 kyber.Point) kyber.Point
+	Finalize() kyber.Point
+}
+
+// pairingProductIsOne checks that product of e(g1<k>, g2<k>) is the identity of GT.
+// It multiplies results of Miller loops and takes one final exponentiation instead of one per pairing.
+// Pairs with the point at infinity are skipped: their pairing is the identity, but the Miller loop does not return it
+func (vk *VerifierKey) pairingProductIsOne(g1, g2 []kyber.Point) bool {
+	null1 := vk.Suite.G1().Point().Null()
+	null2 := vk.Suite.G2().Point().Null()
+	ret := vk.Suite.GT().Point().Null()
+	m := vk.Suite.GT().Point()
+	for k := range g1 {
+		if g1[k].Equal(null1) || g2[k].Equal(null2) {
+			continue
+		}
+		ret.Add(ret, m.(millerLoop).Miller(g1[k], g2[k]))
+	}
+	return ret.(millerLoop).Finalize().Equal(vk.Suite.GT().Point().Null())
+}
+
+// Unblind returns c - blindingValue*H (see TrustedSetup.Unblind)
+func (vk *VerifierKey) Unblind(c kyber.Point, blindingValue kyber.Scalar) kyber.Point {
+	ret := vk.Suite.G1().Point().Mul(blindingValue, blindingGenerator)
+	return ret.Sub(c, ret)
+}
@@ -0,0 +1,116 @@
+package kzg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+func TestVerifierKey(t *testing.T) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	data := tr.VerifierKey().Bytes()
+	t.Logf("verifier key: %d bytes, trusted setup: %d bytes", len(data), len(tr.Bytes()))
+	require.True(t, len(data) < len(tr.Bytes()))
+	vk, err := VerifierKeyFromBytes(suite, data)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, vk.Bytes()))
+
+	vect := make([]kyber.Scalar, D)
+	vect[1] = suite.G1().Scalar().Pick(rnd)
+	vect[200] = suite.G1().Scalar().Pick(rnd)
+	c := tr.Commit(vect)
+	pi := tr.Prove(vect, 200)
+	require.True(t, vk.Verify(c, pi, vect[200], 200))
+	require.False(t, vk.Verify(c, pi, vect[1], 200))
+	require.False(t, vk.Verify(c, pi, vect[200], D))
+	require.True(t, vk.BatchVerify([]Opening{{C: c, Pi: pi, Value: vect[200], Index: 200}}))
+	require.False(t, vk.BatchVerify([]Opening{{C: c, Pi: pi, Value: vect[1], Index: 200}}))
+	require.True(t, BatchVerify(vk, []Opening{{C: c, Pi: pi, Value: vect[200], Index: 200}}))
+	require.False(t, BatchVerify(vk, []Opening{{C: c, Pi: pi, Value: vect[200], Index: 201}}))
+
+	// points at infinity in the pairing check
+	zeros := make([]kyber.Scalar, D)
+	c0 := tr.Commit(zeros)
+	pi0 := tr.Prove(zeros, 5)
+	require.True(t, c0.Equal(suite.G1().Point().Null()))
+	require.True(t, pi0.Equal(suite.G1().Point().Null()))
+	require.True(t, vk.Verify(c0, pi0, tr.ZeroG1, 5))
+	require.False(t, vk.Verify(c0, pi0, vect[1], 5))
+	require.False(t, vk.Verify(c, pi0, vect[200], 200))
+
+	z := suite.G1().Scalar().Pick(rnd)
+	piZ, y := tr.ProveAt(vect, z)
+	require.True(t, vk.VerifyAt(c, piZ, z, y))
+
+	vects := [][]kyber.Scalar{vect, vect}
+	indices := []int{1, 5}
+	agg := tr.ProveAggregated([]kyber.Point{c, c}, vects, indices)
+	require.True(t, vk.VerifyAggregated([]kyber.Point{c, c}, indices, []kyber.Scalar{vect[1], tr.ZeroG1}, agg))
+
+	t.Run("natural domain", func(t *testing.T) {
+		trNatural, err := TrustedSetupFromSeed(suite, 16, []byte("verifier key"))
+		require.NoError(t, err)
+		vk, err := VerifierKeyFromBytes(suite, trNatural.VerifierKey().Bytes())
+		require.NoError(t, err)
+		require.True(t, vk.Domain[3].Equal(suite.G1().Scalar().SetInt64(3)))
+	})
+	t.Run("wrong", func(t *testing.T) {
+		_, err := VerifierKeyFromBytes(suite, tr.Bytes())
+		require.Error(t, err)
+		corrupted := append([]byte{}, data...)
+		corrupted[200]++
+		_, err = VerifierKeyFromBytes(suite, corrupted)
+		require.Error(t, err)
+		wrong := tr.VerifierKey()
+		wrong.Diff2[3] = suite.G2().Point().Pick(rnd)
+		_, err = VerifierKeyFromBytes(suite, wrong.Bytes())
+		require.Error(t, err)
+	})
+}
+
+func benchmarkVerifySetup(b *testing.B) (*VerifierKey, kyber.Point, kyber.Point, kyber.Scalar) {
+	suite := bn256.NewSuite()
+	rnd := random.New()
+	tr, err := TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(b, err)
+	vect := make([]kyber.Scalar, D)
+	for i := range vect {
+		vect[i] = suite.G1().Scalar().Pick(rnd)
+	}
+	c := tr.Commit(vect)
+	return tr.VerifierKey(), c, tr.Prove(vect, 200), vect[200]
+}
+
+// BenchmarkVerify is one check with two Miller loops and one final exponentiation
+func BenchmarkVerify(b *testing.B) {
+	vk, c, pi, v := benchmarkVerifySetup(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !vk.Verify(c, pi, v, 200) {
+			b.Fatal("verification failed")
+		}
+	}
+}
+
+// BenchmarkVerifyTwoPairings is the check with two full pairings, as it was done before
+func BenchmarkVerifyTwoPairings(b *testing.B) {
+	vk, c, pi, v := benchmarkVerifySetup(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p1 := vk.Suite.Pair(pi, vk.Diff2[200])
+		e := vk.Suite.G1().Point().Mul(v, nil)
+		e.Sub(c, e)
+		p2 := vk.Suite.Pair(e, vk.Suite.G2().Point().Base())
+		if !p1.Equal(p2) {
+			b.Fatal("verification failed")
+		}
+	}
+}
@@ -1,6 +1,16 @@
 // the program kzg_setup generates new trusted setup for the KZG calculations from the
 // secret entered from the keyboard and saves generated setup into the file
 // Usage: kzg_setup <file name>
+//
+// The trusted setup can also be generated in the multi-party ceremony, where no single participant
+// knows the secret:
+//
+//	kzg_setup init <ceremony file> [<number of powers>]   creates the ceremony file with secret 1
+//	kzg_setup contribute <in file> <out file>             mixes the secret entered from the keyboard into the ceremony
+//	kzg_setup verify-transcript <ceremony file>           verifies all contributions of the ceremony
+//	kzg_setup finalize <ceremony file> <file name>        derives the trusted setup from the ceremony
+//
+// kzg_setup verify <file name> checks the integrity of the trusted setup in the file
 package main
 
 import (
@@ -8,9 +18,11 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"strconv"
 	"syscall"
 
 	"github.com/lunfardo314/verkle/kzg"
+	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"golang.org/x/crypto/blake2b"
 	"golang.org/x/term"
@@ -23,15 +35,77 @@ const (
 )
 
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Printf("Usage: kzg_setup <file name>\n")
+	cmd := ""
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+	var err error
+	switch {
+	case len(os.Args) < 2:
+		err = generate(defaultFile)
+	case cmd == "init" && (len(os.Args) == 3 || len(os.Args) == 4):
+		n := D
+		if len(os.Args) == 4 {
+			if n, err = strconv.Atoi(os.Args[3]); err != nil || n < D || n > 0xFFFF {
+				fmt.Printf("wrong number of powers '%s'\n", os.Args[3])
+				return
+			}
+		}
+		err = initCeremony(os.Args[2], uint16(n))
+	case cmd == "contribute" && len(os.Args) == 4:
+		err = contribute(os.Args[2], os.Args[3])
+	case cmd == "verify-transcript" && len(os.Args) == 3:
+		err = verifyTranscript(os.Args[2])
+	case cmd == "finalize" && len(os.Args) == 4:
+		err = finalize(os.Args[2], os.Args[3])
+	case cmd == "verify" && len(os.Args) == 3:
+		err = verify(os.Args[2])
+	case cmd == "init" || cmd == "contribute" || cmd == "verify-transcript" || cmd == "finalize" || cmd == "verify":
+		// wrong number of arguments of the command. Not a file name to generate the trusted setup into
+		usage()
+		os.Exit(1)
+	case len(os.Args) == 2:
+		err = generate(os.Args[1])
+	default:
+		usage()
 		return
 	}
-	fname := defaultFile
-	if len(os.Args) == 2 {
-		fname = os.Args[1]
+	if err != nil {
+		fmt.Printf("error: %v\nFAIL\n", err)
+		os.Exit(1)
 	}
+	fmt.Printf("SUCCESS\n")
+}
+
+func usage() {
+	fmt.Printf("Usage: kzg_setup <file name>\n")
+	fmt.Printf("       kzg_setup init <ceremony file> [<number of powers>]\n")
+	fmt.Printf("       kzg_setup contribute <in file> <out file>\n")
+	fmt.Printf("       kzg_setup verify-transcript <ceremony file>\n")
+	fmt.Printf("       kzg_setup finalize <ceremony file> <file name>\n")
+	fmt.Printf("       kzg_setup verify <file name>\n")
+}
+
+// generate generates the trusted setup from one secret
+func generate(fname string) error {
 	fmt.Printf("generating new trusted KXG setup to file '%s'... \n", fname)
+	suite := bn256.NewSuite()
+	s := readSecret(suite)
+	omega, _ := kzg.GenRootOfUnityQuasiPrimitive(suite, D)
+	tr, err := kzg.TrustedSetupFromSecretPowers(suite, D, omega, s)
+	s.Zero() // // destroy secret
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fname, tr.Bytes(), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("success. The trusted setup has been generated and saved into the file '%s'\n", fname)
+	return writePrecalcAndCheck(suite, tr, fname)
+}
+
+// readSecret reads seed from the keyboard and makes the secret of it
+func readSecret(suite *bn256.Suite) kyber.Scalar {
 	var seed []byte
 	var err error
 	for {
@@ -57,23 +131,110 @@ func main() {
 	for i := 0; i < 10+rand.Intn(90); i++ {
 		h = blake2b.Sum256(h[:])
 	}
-	suite := bn256.NewSuite()
 	s := suite.G1().Scalar()
 	s.SetBytes(h[:])
 	h = [32]byte{} // destroy secret
+	return s
+}
+
+// writePrecalcAndCheck writes the sidecar file with precalculated values and reads the trusted setup back
+func writePrecalcAndCheck(suite *bn256.Suite, tr *kzg.TrustedSetup, fname string) error {
+	if err := tr.WritePrecalcFile(fname); err != nil {
+		return err
+	}
+	fmt.Printf("precalculated values have been saved into the file '%s'\n", kzg.PrecalcFileName(fname))
+	tr, err := kzg.TrustedSetupFromFile(suite, fname)
+	if err != nil {
+		return fmt.Errorf("reading trusted setup back from file '%s': %w", fname, err)
+	}
+	fmt.Printf("reading trusted setup back from file '%s': OK\n", fname)
+	if err = tr.Validate(); err != nil {
+		return fmt.Errorf("validating trusted setup: %w", err)
+	}
+	fmt.Printf("validating trusted setup: OK\n")
+	return nil
+}
+
+// verify reads the trusted setup from the file and validates it
+func verify(fname string) error {
+	tr, err := kzg.TrustedSetupFromFile(bn256.NewSuite(), fname)
+	if err != nil {
+		return err
+	}
+	if err = tr.Validate(); err != nil {
+		return err
+	}
+	fmt.Printf("the trusted setup of degree %d in the file '%s' is valid\n", tr.D, fname)
+	return nil
+}
+
+func initCeremony(fname string, n uint16) error {
+	c, err := kzg.NewCeremony(bn256.NewSuite(), n, n)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fname, c.Bytes(), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("the ceremony with %d powers has been initialized in the file '%s'\n", n, fname)
+	return nil
+}
+
+func contribute(inFile, outFile string) error {
+	suite := bn256.NewSuite()
+	c, err := kzg.CeremonyFromFile(suite, inFile)
+	if err != nil {
+		return err
+	}
+	if err = c.VerifyTranscript(); err != nil {
+		return fmt.Errorf("verifying transcript of '%s': %w", inFile, err)
+	}
+	fmt.Printf("contributing to the ceremony with %d contribution(s) from the file '%s'... \n", len(c.Contributions), inFile)
+	s := readSecret(suite)
+	err = c.Contribute(s)
+	s.Zero() // destroy secret
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(outFile, c.Bytes(), 0600); err != nil {
+		return err
+	}
+	contr := c.Contributions[len(c.Contributions)-1]
+	fmt.Printf("the contribution has been saved into the file '%s'. Publish your public key:\n%s\n", outFile, contr.PubKey)
+	return nil
+}
+
+func verifyTranscript(fname string) error {
+	c, err := kzg.CeremonyFromFile(bn256.NewSuite(), fname)
+	if err != nil {
+		return err
+	}
+	if err = c.VerifyTranscript(); err != nil {
+		return err
+	}
+	fmt.Printf("the transcript of %d contribution(s) in the file '%s' is valid. Public keys:\n", len(c.Contributions), fname)
+	for i, contr := range c.Contributions {
+		fmt.Printf("%d: %s\n", i, contr.PubKey)
+	}
+	return nil
+}
+
+func finalize(ceremonyFile, fname string) error {
+	suite := bn256.NewSuite()
+	c, err := kzg.CeremonyFromFile(suite, ceremonyFile)
+	if err != nil {
+		return err
+	}
 	omega, _ := kzg.GenRootOfUnityQuasiPrimitive(suite, D)
-	tr, err := kzg.TrustedSetupFromSecretPowers(suite, D, omega, s)
-	s.Zero() // // destroy secret
+	tr, err := c.Finalize(D, omega)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	// powers of tau are kept in the file, so trusted setups of other degrees can be derived from it
+	tr.PowersOfTau = c.Powers
 	if err = ioutil.WriteFile(fname, tr.Bytes(), 0600); err != nil {
-		panic(err)
-	}
-	fmt.Printf("success. The trusted setup has been generated and saved into the file '%s'\n", fname)
-	if _, err = kzg.TrustedSetupFromFile(suite, fname); err != nil {
-		fmt.Printf("reading trusted setup back from file '%s': %v\nFAIL\n", fname, err)
-	} else {
-		fmt.Printf("reading trusted setup back from file '%s': OK\nSUCCESS\n", fname)
+		return err
 	}
+	fmt.Printf("the trusted setup has been derived from %d contribution(s) and saved into the file '%s'\n", len(c.Contributions), fname)
+	return writePrecalcAndCheck(suite, tr, fname)
 }
@@ -0,0 +1,86 @@
+package trie
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+// VectorCommitment is the scheme the trie uses to commit to vectors of 257 elements of its nodes.
+// Commitments are points of G1 of the bn256 suite, elements of vectors are scalars of it.
+// The scheme must be additively homomorphic: Commit(a+b) == Commit(a)+Commit(b), so commitments
+// of nodes along the path are updated with CommitDelta instead of recalculating them from the whole vector.
+// The default scheme is KZG (see NewKZG).
+// Optional features of the trie, such as hidden nodes, the proof cache and aggregated proofs, are available only
+// if the scheme implements corresponding interfaces: HidingCommitment, ProofUpdater and Aggregator
+type VectorCommitment interface {
+	Suite() *bn256.Suite
+	// Commit returns commitment to the vector. nil elements of the vector are zeros
+	Commit(vect []kyber.Scalar) kyber.Point
+	// CommitDelta returns commitment to the vector with delta at position i and zeros elsewhere,
+	// i.e. the change of the commitment when the element i of the vector changes by delta
+	CommitDelta(i int, delta kyber.Scalar) kyber.Point
+	// Prove returns proof of the element i of the vector
+	Prove(vect []kyber.Scalar, i int) OpeningProof
+	// Verifier returns the verifier of proofs created with the scheme
+	Verifier() Verifier
+	// Bytes returns parameters of the scheme. The state commits to them at the nil key
+	Bytes() []byte
+}
+
+// Verifier verifies proofs of elements of committed vectors. It is the only part of the scheme
+// needed to verify proofs of the trie.
+// Optionally it implements HidingVerifier, BatchVerifier and AggregateVerifier
+type Verifier interface {
+	Suite() *bn256.Suite
+	// Verify checks the proof that the vector committed with c has value v at position i
+	Verify(c kyber.Point, proof OpeningProof, v kyber.Scalar, i int) bool
+}
+
+// OpeningProof is the proof of the element of the committed vector. Its type depends on the scheme.
+// Proofs are never modified by the trie. Proofs of type kyber.Point are copied when taken from the proof cache,
+// proofs of other types must not be modified by the caller
+type OpeningProof interface {
+	MarshalBinary() ([]byte, error)
+}
+
+// AggregatedProof is the proof of openings of many vectors at once. Its type depends on the scheme
+type AggregatedProof interface {
+	MarshalBinary() ([]byte, error)
+}
+
+// HidingCommitment is implemented by schemes with hiding commitments Commit(vect) + CommitBlinding(blinding).
+// It is needed for hidden nodes (see State.HidePrefix)
+type HidingCommitment interface {
+	SupportsHiding() bool
+	CommitBlinding(blinding []kyber.Scalar) kyber.Point
+	ProveHiding(vect, blinding []kyber.Scalar, i int) OpeningProof
+}
+
+// HidingVerifier is implemented by verifiers of schemes with hiding commitments.
+// Unblind removes blinding from the hiding commitment, given the value of the blinding vector at the opened position
+type HidingVerifier interface {
+	Unblind(c kyber.Point, blindingValue kyber.Scalar) kyber.Point
+}
+
+// ProofUpdater is implemented by schemes where the proof can be updated after the change of the vector,
+// without recalculating it. It is needed to keep the proof cache (see State.EnableProofCache) valid across updates.
+// ProveAll returns proofs of all positions of the vector
+type ProofUpdater interface {
+	UpdateProof(pi OpeningProof, i, j int, delta kyber.Scalar) OpeningProof
+	ProveAll(vect []kyber.Scalar) []OpeningProof
+}
+
+// Aggregator is implemented by schemes which can aggregate openings of many vectors into one proof
+type Aggregator interface {
+	ProveAggregated(commitments []kyber.Point, vects [][]kyber.Scalar, indices []int) AggregatedProof
+}
+
+// AggregateVerifier is implemented by verifiers of schemes, which implement Aggregator
+type AggregateVerifier interface {
+	VerifyAggregated(commitments []kyber.Point, indices []int, values []kyber.Scalar, proof AggregatedProof) bool
+}
+
+// BatchVerifier is implemented by verifiers which can check many openings at once cheaper than one by one
+type BatchVerifier interface {
+	BatchVerify(commitments []kyber.Point, proofs []OpeningProof, values []kyber.Scalar, indices []int) bool
+}
@@ -0,0 +1,177 @@
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/lunfardo314/verkle/ipa"
+	"github.com/lunfardo314/verkle/kzg"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+// pedersenCommitment is a transparent vector commitment scheme to test the trie with a scheme other than KZG.
+// The commitment is sum<i>(vect<i>*G<i>) with generators G<i> hashed to the curve.
+// The proof of the element is the whole vector, so it only implements the mandatory part of VectorCommitment
+type pedersenCommitment struct {
+	suite *bn256.Suite
+	bases []kyber.Point
+}
+
+type pedersenProof []kyber.Scalar
+
+func newPedersenCommitment() *pedersenCommitment {
+	suite := bn256.NewSuite()
+	ret := &pedersenCommitment{
+		suite: suite,
+		bases: make([]kyber.Point, 257),
+	}
+	for i := range ret.bases {
+		ret.bases[i] = suite.G1().Point().(hashablePoint).Hash([]byte(fmt.Sprintf("pedersen %d", i)))
+	}
+	return ret
+}
+
+func (p *pedersenCommitment) Suite() *bn256.Suite {
+	return p.suite
+}
+
+func (p *pedersenCommitment) Commit(vect []kyber.Scalar) kyber.Point {
+	ret := p.suite.G1().Point().Null()
+	for i, v := range vect {
+		if v != nil {
+			ret.Add(ret, p.CommitDelta(i, v))
+		}
+	}
+	return ret
+}
+
+func (p *pedersenCommitment) CommitDelta(i int, delta kyber.Scalar) kyber.Point {
+	return p.suite.G1().Point().Mul(delta, p.bases[i])
+}
+
+func (p *pedersenCommitment) Prove(vect []kyber.Scalar, _ int) OpeningProof {
+	ret := make(pedersenProof, len(vect))
+	for i, v := range vect {
+		if v == nil {
+			ret[i] = p.suite.G1().Scalar().Zero()
+		} else {
+			ret[i] = v.Clone()
+		}
+	}
+	return ret
+}
+
+func (p *pedersenCommitment) Verifier() Verifier {
+	return p
+}
+
+func (p *pedersenCommitment) Verify(c kyber.Point, proof OpeningProof, v kyber.Scalar, i int) bool {
+	vect, ok := proof.(pedersenProof)
+	if !ok || len(vect) != len(p.bases) || i < 0 || i >= len(vect) || !vect[i].Equal(v) {
+		return false
+	}
+	return p.Commit(vect).Equal(c)
+}
+
+func (p *pedersenCommitment) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, b := range p.bases {
+		if _, err := b.MarshalTo(&buf); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func (pr pedersenProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, v := range pr {
+		if _, err := v.MarshalTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func TestVectorCommitment(t *testing.T) {
+	vc := newPedersenCommitment()
+	st1 := NewStateWithCommitment(vc)
+	st2 := NewStateWithCommitment(vc)
+	c1 := UpdateKeys(st1, kvpairs1)
+	c2 := UpdateKeys(st2, RandomizeKeys(kvpairs1))
+	require.True(t, c1.Equal(c2))
+	require.True(t, st1.Check(vc))
+
+	ts, err := kzg.TrustedSetupFromFile(bn256.NewSuite(), "example.setup")
+	require.NoError(t, err)
+	stKZG := NewState(ts)
+	require.False(t, c1.Equal(UpdateKeys(stKZG, kvpairs1)))
+	require.False(t, st1.Check(NewKZG(ts)))
+
+	st1.EnableProofCache(true)
+	proofs := make([]*Proof, 0)
+	for _, kv := range kvpairs1 {
+		proof, ok := st1.ProveStr(kv.key)
+		require.True(t, ok)
+		require.NoError(t, VerifyProof(vc.Verifier(), proof))
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		proofs = append(proofs, proof)
+	}
+	for _, kv := range kvpairsNotInState {
+		proof, ok := st1.ProveStr(kv.key)
+		require.False(t, ok)
+		require.NoError(t, VerifyProof(vc.Verifier(), proof))
+		proofs = append(proofs, proof)
+	}
+	require.NoError(t, VerifyProofs(vc.Verifier(), proofs...))
+
+	// the scheme does not update proofs, so cached proofs of changed nodes are dropped
+	st1.UpdateStr("abrak2", "new value")
+	require.True(t, st1.DeleteStr("abrak3a"))
+	st1.FlushCaches()
+	for _, key := range []string{"abrak2", "abrak3ab", "a"} {
+		proof, ok := st1.ProveStr(key)
+		require.True(t, ok)
+		require.NoError(t, VerifyProof(vc.Verifier(), proof))
+	}
+	require.True(t, st1.CacheNodeProofs(nil))
+	proof, ok := st1.ProveStr("abrak3abc")
+	require.True(t, ok)
+	require.NoError(t, VerifyProof(vc.Verifier(), proof))
+
+	// the scheme does not support aggregation and hiding
+	proof, ok = st1.ProveAggregatedStr("abrak2")
+	require.True(t, ok)
+	require.Nil(t, proof.Aggregated)
+	require.NoError(t, VerifyProof(vc.Verifier(), proof))
+	require.Error(t, st1.HidePrefix([]byte("hidden")))
+}
+
+func TestIPA(t *testing.T) {
+	params, err := ipa.NewParams(bn256.NewSuite(), 257)
+	require.NoError(t, err)
+	vc := NewIPA(params)
+	st1 := NewStateWithCommitment(vc)
+	st2 := NewStateWithCommitment(vc)
+	c1 := UpdateKeys(st1, kvpairs1)
+	c2 := UpdateKeys(st2, RandomizeKeys(kvpairs1))
+	require.True(t, c1.Equal(c2))
+	require.True(t, st1.Check(vc))
+
+	proofs := make([]*Proof, 0)
+	for _, key := range []string{"a", "abrak2", "abrak3abc"} {
+		proof, ok := st1.ProveStr(key)
+		require.True(t, ok)
+		proofs = append(proofs, proof)
+	}
+	proof, ok := st1.ProveStr("abrak3!!")
+	require.False(t, ok)
+	proofs = append(proofs, proof)
+	require.NoError(t, VerifyProofs(vc.Verifier(), proofs...))
+
+	proofs[1].Value = []byte("wrong")
+	require.Error(t, VerifyProof(vc.Verifier(), proofs[1]))
+}
@@ -0,0 +1,534 @@
+package trie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+// Flusher is implemented by key/value stores which buffer writes. Flush makes written data durable.
+// FlushCaches does not call it: the commit of the FileKVStore batch flushes the store itself, and WALKVStore
+// flushes the wrapped store after the batch is applied and before the log is cleared
+type Flusher interface {
+	Flush() error
+}
+
+// FileKVStore is a log-structured key/value store in one append-only data file.
+// Each Set and Del appends the record to the file, the in-memory index points to the latest value of each key.
+// The batch is written as one record, so it is either read as a whole or not at all.
+// The index is rebuilt by reading all records when the file is opened. A corrupted or incomplete record
+// at the end of the file, left by a crash in the middle of the write, is truncated away.
+// Flush writes buffered records to the file and syncs it to the disk. It also compacts the file,
+// i.e. rewrites it with only live records, when the garbage takes most of the file.
+// Errors of the file system in Set, Del and Get are panics
+type FileKVStore struct {
+	fname   string
+	file    *os.File
+	w       *bufio.Writer
+	index   map[string]fileKVIndexEntry
+	keys    keyIndex
+	size    int64 // size of the file including buffered records
+	flushed int64 // size of the file which can be read with ReadAt
+	garbage int64 // total size of overwritten and deleted records
+}
+
+type fileKVIndexEntry struct {
+	offset     int64 // offset of the value in the file
+	size       uint32
+	recordSize int64 // size of the record or of the operation in the batch record
+}
+
+// The record of the data file:
+// - crc32 (IEEE) of the rest of the record (uint32)
+// - operation or the batch of operations
+// The operation:
+// - op (byte): fileKVSet or fileKVDel
+// - size of the key (uvarint)
+// - size of the value (uvarint), only for fileKVSet
+// - key
+// - value, only for fileKVSet
+// The batch:
+// - op (byte): fileKVBatch
+// - number of operations (uvarint)
+// - operations
+
+const (
+	fileKVSet   = byte(1)
+	fileKVDel   = byte(2)
+	fileKVBatch = byte(3)
+)
+
+const (
+	// the file is compacted upon Flush if the garbage is more than half of the file and bigger than this size
+	fileKVCompactMinGarbage = 1 << 20
+	fileKVWriteBufferSize   = 1 << 16
+)
+
+const (
+	// MaxFileKVKeySize is the maximal size of the key in FileKVStore
+	MaxFileKVKeySize = 1 << 16
+	// MaxFileKVValueSize is the maximal size of the value in FileKVStore
+	MaxFileKVValueSize = 1<<31 - 1
+)
+
+var errCorruptedRecord = xerrors.New("corrupted record")
+
+// checkFileKVLimits returns error if the key or the value of any operation is too big to be written in the record
+func checkFileKVLimits(ops []batchOp) error {
+	for _, op := range ops {
+		if len(op.key) > MaxFileKVKeySize {
+			return xerrors.Errorf("key of %d bytes is longer than %d bytes", len(op.key), MaxFileKVKeySize)
+		}
+		if len(op.value) > MaxFileKVValueSize {
+			return xerrors.Errorf("value of %d bytes is longer than %d bytes", len(op.value), MaxFileKVValueSize)
+		}
+	}
+	return nil
+}
+
+// OpenFileKVStore opens the store in the file. The file is created if it does not exist
+func OpenFileKVStore(fname string) (*FileKVStore, error) {
+	file, err := os.OpenFile(fname, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ret := &FileKVStore{
+		fname: fname,
+		file:  file,
+		index: make(map[string]fileKVIndexEntry),
+	}
+	if err = ret.readIndex(); err != nil {
+		_ = file.Close()
+		return nil, xerrors.Errorf("reading data file '%s': %w", fname, err)
+	}
+	ret.w = bufio.NewWriterSize(file, fileKVWriteBufferSize)
+	return ret, nil
+}
+
+// readIndex reads all records of the file and builds the index.
+// The incomplete or corrupted last record, left by the crash during the write, is truncated away.
+// The corrupted record followed by more data is not the result of the crash, so it is an error
+func (s *FileKVStore) readIndex() error {
+	fi, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err = s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(s.file)
+	var offset int64
+	for {
+		ops, recordSize, err := readFileKVRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF || xerrors.Is(err, errCorruptedRecord) {
+			last := err == io.ErrUnexpectedEOF || offset+recordSize == fi.Size()
+			if !last {
+				if last, err = s.isZeroTail(offset, fi.Size()); err != nil {
+					return err
+				}
+			}
+			if !last {
+				return xerrors.Errorf("corrupted record at offset %d", offset)
+			}
+			if err = s.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			s.updateIndex(op.op, op.key, offset+op.valueOffset, uint32(len(op.value)), op.size)
+		}
+		offset += recordSize
+	}
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	s.size = offset
+	s.flushed = offset
+	return nil
+}
+
+// isZeroTail returns true if the file contains only zeros from the offset to the end.
+// The file system may leave such a tail after the crash, when the size of the file was updated but the data was not
+func (s *FileKVStore) isZeroTail(offset, size int64) (bool, error) {
+	buf := make([]byte, fileKVWriteBufferSize)
+	for offset < size {
+		n := int64(len(buf))
+		if size-offset < n {
+			n = size - offset
+		}
+		if _, err := s.file.ReadAt(buf[:n], offset); err != nil {
+			return false, err
+		}
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		offset += n
+	}
+	return true, nil
+}
+
+// updateIndex updates the index with the operation written to the file
+func (s *FileKVStore) updateIndex(op byte, key string, valueOffset int64, valueSize uint32, size int64) {
+	old, exists := s.index[key]
+	if exists {
+		s.garbage += old.recordSize
+	}
+	switch op {
+	case fileKVSet:
+		s.index[key] = fileKVIndexEntry{
+			offset:     valueOffset,
+			size:       valueSize,
+			recordSize: size,
+		}
+		if !exists {
+			s.keys.insert(key)
+		}
+	case fileKVDel:
+		s.garbage += size
+		if exists {
+			delete(s.index, key)
+			s.keys.remove(key)
+		}
+	}
+}
+
+// fileKVOp is the operation read from the record
+type fileKVOp struct {
+	op          byte
+	key         string
+	value       []byte
+	valueOffset int64 // offset of the value from the start of the record
+	size        int64 // size of the operation, or of the whole record if it contains one operation
+}
+
+// fileKVRecordReader reads the record and calculates its checksum
+type fileKVRecordReader struct {
+	r      *bufio.Reader
+	crc    hash.Hash32
+	offset int64
+}
+
+func (rr *fileKVRecordReader) ReadByte() (byte, error) {
+	b, err := rr.r.ReadByte()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	rr.crc.Write([]byte{b})
+	rr.offset++
+	return b, nil
+}
+
+func (rr *fileKVRecordReader) readOp(op byte) (fileKVOp, error) {
+	start := rr.offset - 1
+	keySize, err := binary.ReadUvarint(rr)
+	if err != nil {
+		return fileKVOp{}, io.ErrUnexpectedEOF
+	}
+	var valueSize uint64
+	if op == fileKVSet {
+		if valueSize, err = binary.ReadUvarint(rr); err != nil {
+			return fileKVOp{}, io.ErrUnexpectedEOF
+		}
+	}
+	if keySize > MaxFileKVKeySize || valueSize > MaxFileKVValueSize {
+		return fileKVOp{}, errCorruptedRecord
+	}
+	data := make([]byte, keySize+valueSize)
+	if _, err = io.ReadFull(rr.r, data); err != nil {
+		return fileKVOp{}, io.ErrUnexpectedEOF
+	}
+	rr.crc.Write(data)
+	rr.offset += int64(len(data))
+	return fileKVOp{
+		op:          op,
+		key:         string(data[:keySize]),
+		value:       data[keySize:],
+		valueOffset: rr.offset - int64(valueSize),
+		size:        rr.offset - start,
+	}, nil
+}
+
+// readFileKVRecord reads one record. Returns operations of the record and size of the record.
+// The size is also returned with errCorruptedRecord if the record is complete but its checksum is wrong
+func readFileKVRecord(r *bufio.Reader) ([]fileKVOp, int64, error) {
+	var crcBuf [4]byte
+	if _, err := io.ReadFull(r, crcBuf[:]); err != nil {
+		return nil, 0, err
+	}
+	rr := &fileKVRecordReader{
+		r:      r,
+		crc:    crc32.NewIEEE(),
+		offset: int64(len(crcBuf)),
+	}
+	op, err := rr.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+	var ret []fileKVOp
+	switch op {
+	case fileKVSet, fileKVDel:
+		o, err := rr.readOp(op)
+		if err != nil {
+			return nil, 0, err
+		}
+		o.size = rr.offset
+		ret = append(ret, o)
+	case fileKVBatch:
+		n, err := binary.ReadUvarint(rr)
+		if err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		for i := uint64(0); i < n; i++ {
+			op, err = rr.ReadByte()
+			if err != nil {
+				return nil, 0, err
+			}
+			if op != fileKVSet && op != fileKVDel {
+				return nil, 0, errCorruptedRecord
+			}
+			o, err := rr.readOp(op)
+			if err != nil {
+				return nil, 0, err
+			}
+			ret = append(ret, o)
+		}
+	default:
+		return nil, 0, errCorruptedRecord
+	}
+	if rr.crc.Sum32() != binary.LittleEndian.Uint32(crcBuf[:]) {
+		return nil, rr.offset, errCorruptedRecord
+	}
+	return ret, rr.offset, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// encodeFileKVRecord encodes operations into one record. Returns the record, offsets of values
+// and sizes of operations as they are defined in fileKVOp
+func encodeFileKVRecord(ops []batchOp) ([]byte, []int64, []int64) {
+	buf := make([]byte, 4, 64)
+	if len(ops) != 1 {
+		buf = append(buf, fileKVBatch)
+		buf = appendUvarint(buf, uint64(len(ops)))
+	}
+	offsets := make([]int64, len(ops))
+	sizes := make([]int64, len(ops))
+	for i, op := range ops {
+		start := len(buf)
+		if op.del {
+			buf = append(buf, fileKVDel)
+			buf = appendUvarint(buf, uint64(len(op.key)))
+			buf = append(buf, op.key...)
+		} else {
+			buf = append(buf, fileKVSet)
+			buf = appendUvarint(buf, uint64(len(op.key)))
+			buf = appendUvarint(buf, uint64(len(op.value)))
+			buf = append(buf, op.key...)
+			buf = append(buf, op.value...)
+		}
+		offsets[i] = int64(len(buf) - len(op.value))
+		sizes[i] = int64(len(buf) - start)
+	}
+	if len(ops) == 1 {
+		sizes[0] = int64(len(buf))
+	}
+	binary.LittleEndian.PutUint32(buf[:4], crc32.ChecksumIEEE(buf[4:]))
+	return buf, offsets, sizes
+}
+
+// appendRecord appends the record with operations to the write buffer and updates the index
+func (s *FileKVStore) appendRecord(ops []batchOp) {
+	record, offsets, sizes := encodeFileKVRecord(ops)
+	_, err := s.w.Write(record)
+	assert(err == nil, err)
+	for i, op := range ops {
+		if op.del {
+			s.updateIndex(fileKVDel, string(op.key), 0, 0, sizes[i])
+		} else {
+			s.updateIndex(fileKVSet, string(op.key), s.size+offsets[i], uint32(len(op.value)), sizes[i])
+		}
+	}
+	s.size += int64(len(record))
+}
+
+// Set panics if the key or the value is too big (see MaxFileKVKeySize and MaxFileKVValueSize)
+func (s *FileKVStore) Set(k []byte, v []byte) {
+	ops := []batchOp{{key: k, value: v}}
+	err := checkFileKVLimits(ops)
+	assert(err == nil, err)
+	s.appendRecord(ops)
+}
+
+func (s *FileKVStore) Del(k []byte) {
+	if len(k) == 0 || !s.Has(k) {
+		return
+	}
+	s.appendRecord([]batchOp{{del: true, key: k}})
+}
+
+// Batch is written to the file as one record. Commit flushes the store.
+// Commit returns error and writes nothing if the key or the value of any operation is too big
+func (s *FileKVStore) Batch() Batch {
+	return &batch{commit: func(ops []batchOp) error {
+		if err := checkFileKVLimits(ops); err != nil {
+			return err
+		}
+		filtered := ops[:0]
+		for _, op := range ops {
+			// like Del of the store, deletion of the nil key is ignored
+			if !op.del || len(op.key) != 0 {
+				filtered = append(filtered, op)
+			}
+		}
+		if len(filtered) > 0 {
+			s.appendRecord(filtered)
+		}
+		return s.Flush()
+	}}
+}
+
+func (s *FileKVStore) Get(k []byte) ([]byte, bool) {
+	e, ok := s.index[string(k)]
+	if !ok {
+		return nil, false
+	}
+	if e.offset+int64(e.size) > s.flushed {
+		// the value is still in the write buffer
+		assert(s.w.Flush() == nil, "can't write to the data file")
+		s.flushed = s.size
+	}
+	ret := make([]byte, e.size)
+	_, err := s.file.ReadAt(ret, e.offset)
+	assert(err == nil, err)
+	return ret, true
+}
+
+func (s *FileKVStore) Has(k []byte) bool {
+	_, ok := s.index[string(k)]
+	return ok
+}
+
+func (s *FileKVStore) Partition(prefix string) KVStore {
+	return &partition{
+		store:  s,
+		prefix: prefix,
+	}
+}
+
+func (s *FileKVStore) Iterate(prefix []byte, fn func(k, v []byte) bool) {
+	s.keys.iterate(string(prefix), func(key string) bool {
+		v, _ := s.Get([]byte(key))
+		return fn([]byte(key), v)
+	})
+}
+
+func (s *FileKVStore) IterateKeys(prefix []byte, fn func(k []byte) bool) {
+	s.keys.iterate(string(prefix), func(key string) bool {
+		return fn([]byte(key))
+	})
+}
+
+func (s *FileKVStore) Size() int {
+	return len(s.index)
+}
+
+func (s *FileKVStore) countPrefix(prefix string) int {
+	return s.keys.count(prefix)
+}
+
+// Flush writes all buffered records to the data file and syncs it to the disk.
+// The file is compacted if the garbage takes more than half of it
+func (s *FileKVStore) Flush() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	s.flushed = s.size
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	if s.garbage > fileKVCompactMinGarbage && 2*s.garbage > s.size {
+		return s.Compact()
+	}
+	return nil
+}
+
+// Compact rewrites the data file with only live records. The new file is written next to the data file
+// and renamed over it, so a crash leaves either the old or the new file
+func (s *FileKVStore) Compact() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	s.flushed = s.size
+	tmpName := s.fname + ".compact"
+	tmp, err := os.OpenFile(tmpName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	compacted := &FileKVStore{
+		fname: s.fname,
+		file:  tmp,
+		w:     bufio.NewWriterSize(tmp, fileKVWriteBufferSize),
+		index: make(map[string]fileKVIndexEntry, len(s.index)),
+	}
+	s.Iterate(nil, func(k, v []byte) bool {
+		compacted.Set(k, v)
+		return true
+	})
+	if err = compacted.w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = os.Rename(tmpName, s.fname); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = syncDir(filepath.Dir(s.fname)); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	_ = s.file.Close()
+	compacted.flushed = compacted.size
+	*s = *compacted
+	return nil
+}
+
+// syncDir makes the rename in the directory durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Close flushes and closes the data file
+func (s *FileKVStore) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
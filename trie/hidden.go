@@ -0,0 +1,84 @@
+package trie
+
+import (
+	"bytes"
+
+	"go.dedis.ch/kyber/v3"
+	"golang.org/x/xerrors"
+)
+
+// HidePrefix marks keys with the prefix as hidden. Nodes of the trie, which only commit to hidden keys,
+// use hiding commitments (see HidingCommitment) with the random blinding, so low entropy
+// values can't be brute-forced from commitments. The blinding is replaced with the fresh one each time the node changes.
+// The proof of the key reveals blinding values of the hidden nodes along the path only at the positions it opens.
+// Hidden nodes make commitments random, so the root commitment does not only depend on the key/value pairs in the state.
+// The vector commitment scheme must support hiding commitments (for KZG, the trusted setup must support them).
+// The prefix must be marked before any key with the prefix is stored in the state
+func (st *State) HidePrefix(prefix []byte) error {
+	if len(prefix) == 0 {
+		return xerrors.New("empty prefix can't be hidden")
+	}
+	if h, ok := st.vc.(HidingCommitment); !ok || !h.SupportsHiding() {
+		return xerrors.New("vector commitment scheme does not support hiding commitments")
+	}
+	for k, v := range st.valueCache {
+		if v != nil && bytes.HasPrefix([]byte(k), prefix) {
+			return xerrors.Errorf("state already contains keys with the prefix '%s'", string(prefix))
+		}
+	}
+	found := false
+	st.values.IterateKeys(prefix, func([]byte) bool {
+		found = true
+		return false
+	})
+	if found {
+		return xerrors.Errorf("state already contains keys with the prefix '%s'", string(prefix))
+	}
+	p := make([]byte, len(prefix))
+	copy(p, prefix)
+	st.hiddenPrefixes = append(st.hiddenPrefixes, p)
+	st.hiddenCache = append(st.hiddenCache, p)
+	return nil
+}
+
+// isHidden returns true if all keys committed by the node with the key and path fragment are hidden
+func (st *State) isHidden(key, pathFragment []byte) bool {
+	if len(st.hiddenPrefixes) == 0 {
+		return false
+	}
+	k := make([]byte, 0, len(key)+len(pathFragment))
+	k = append(k, key...)
+	k = append(k, pathFragment...)
+	for _, prefix := range st.hiddenPrefixes {
+		if bytes.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reblind sets the fresh blinding of the node if it is hidden and removes the blinding otherwise.
+// It is used when the node is created or changes its structure, before the commitment of the node is calculated
+func (st *State) reblind(key []byte, node *Node) {
+	if st.isHidden(key, node.pathFragment) {
+		node.blindingSeed = newBlindingSeed()
+	} else {
+		node.blindingSeed = nil
+	}
+}
+
+// rerandomize replaces the blinding of the hidden node with the fresh one and returns the change of its commitment.
+// Returns nil if the node is not hidden
+func (st *State) rerandomize(key []byte, node *Node) kyber.Point {
+	if node.blindingSeed == nil {
+		return nil
+	}
+	oldBlinding := node.blinding(st.vc.Suite())
+	node.blindingSeed = newBlindingSeed()
+	delta := node.blinding(st.vc.Suite())
+	for i := range delta {
+		delta[i].Sub(delta[i], oldBlinding[i])
+	}
+	st.invalidateCachedProofs(key)
+	return st.vc.(HidingCommitment).CommitBlinding(delta)
+}
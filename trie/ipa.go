@@ -0,0 +1,54 @@
+package trie
+
+import (
+	"github.com/lunfardo314/verkle/ipa"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+// IPA is the vector commitment scheme of the trie without the trusted setup: Pedersen vector commitments
+// with openings proved by the inner product argument (see package ipa).
+// Parameters of the scheme are public, so it is its own verifier. Proofs are *ipa.Proof.
+// It does not implement optional features of the scheme
+type IPA struct {
+	params *ipa.Params
+}
+
+// NewIPA creates the IPA scheme with parameters. Parameters must be for vectors of size 257
+func NewIPA(params *ipa.Params) *IPA {
+	assert(params.D == 257, "IPA parameters for vectors of size 257 expected")
+	return &IPA{params: params}
+}
+
+func (p *IPA) Params() *ipa.Params {
+	return p.params
+}
+
+func (p *IPA) Suite() *bn256.Suite {
+	return p.params.Suite
+}
+
+func (p *IPA) Commit(vect []kyber.Scalar) kyber.Point {
+	return p.params.Commit(vect)
+}
+
+func (p *IPA) CommitDelta(i int, delta kyber.Scalar) kyber.Point {
+	return p.params.Suite.G1().Point().Mul(delta, p.params.G[i])
+}
+
+func (p *IPA) Prove(vect []kyber.Scalar, i int) OpeningProof {
+	return p.params.Prove(vect, i)
+}
+
+func (p *IPA) Verifier() Verifier {
+	return p
+}
+
+func (p *IPA) Bytes() []byte {
+	return p.params.Bytes()
+}
+
+func (p *IPA) Verify(c kyber.Point, proof OpeningProof, v kyber.Scalar, i int) bool {
+	pi, ok := proof.(*ipa.Proof)
+	return ok && p.params.Verify(c, pi, v, i)
+}
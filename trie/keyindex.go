@@ -0,0 +1,112 @@
+package trie
+
+import (
+	"sort"
+	"strings"
+)
+
+// keyIndex keeps keys of the store in the lexicographic order.
+// Keys are kept in the sorted list of sorted chunks, so the insertion and the deletion only move keys
+// of one chunk and the scan of keys with the prefix starts with the binary search
+type keyIndex struct {
+	chunks [][]string
+}
+
+const keyIndexChunkSize = 256
+
+// findChunk returns index of the first chunk which may contain the key
+func (idx *keyIndex) findChunk(key string) int {
+	return sort.Search(len(idx.chunks), func(i int) bool {
+		chunk := idx.chunks[i]
+		return chunk[len(chunk)-1] >= key
+	})
+}
+
+// insert adds the key to the index. The key must not be in the index
+func (idx *keyIndex) insert(key string) {
+	if len(idx.chunks) == 0 {
+		idx.chunks = [][]string{{key}}
+		return
+	}
+	ci := idx.findChunk(key)
+	if ci == len(idx.chunks) {
+		ci--
+	}
+	chunk := idx.chunks[ci]
+	pos := sort.SearchStrings(chunk, key)
+	chunk = append(chunk, "")
+	copy(chunk[pos+1:], chunk[pos:])
+	chunk[pos] = key
+	if len(chunk) < 2*keyIndexChunkSize {
+		idx.chunks[ci] = chunk
+		return
+	}
+	// split the chunk in halves
+	left := make([]string, keyIndexChunkSize, 2*keyIndexChunkSize)
+	right := make([]string, len(chunk)-keyIndexChunkSize, 2*keyIndexChunkSize)
+	copy(left, chunk[:keyIndexChunkSize])
+	copy(right, chunk[keyIndexChunkSize:])
+	idx.chunks = append(idx.chunks, nil)
+	copy(idx.chunks[ci+2:], idx.chunks[ci+1:])
+	idx.chunks[ci] = left
+	idx.chunks[ci+1] = right
+}
+
+// remove deletes the key from the index, if present
+func (idx *keyIndex) remove(key string) {
+	ci := idx.findChunk(key)
+	if ci == len(idx.chunks) {
+		return
+	}
+	chunk := idx.chunks[ci]
+	pos := sort.SearchStrings(chunk, key)
+	if pos == len(chunk) || chunk[pos] != key {
+		return
+	}
+	if len(chunk) == 1 {
+		idx.chunks = append(idx.chunks[:ci], idx.chunks[ci+1:]...)
+		return
+	}
+	idx.chunks[ci] = append(chunk[:pos], chunk[pos+1:]...)
+}
+
+// iterate calls fn for each key with the prefix in the lexicographic order until fn returns false.
+// The index must not be modified by fn
+func (idx *keyIndex) iterate(prefix string, fn func(key string) bool) {
+	ci := idx.findChunk(prefix)
+	if ci == len(idx.chunks) {
+		return
+	}
+	pos := sort.SearchStrings(idx.chunks[ci], prefix)
+	for ; ci < len(idx.chunks); ci++ {
+		for _, k := range idx.chunks[ci][pos:] {
+			if !strings.HasPrefix(k, prefix) || !fn(k) {
+				return
+			}
+		}
+		pos = 0
+	}
+}
+
+// count returns number of keys with the prefix. Keys with the prefix are contiguous in the index, so only
+// the first and the last chunk of them are searched, the other chunks are counted whole
+func (idx *keyIndex) count(prefix string) int {
+	ci := idx.findChunk(prefix)
+	if ci == len(idx.chunks) {
+		return 0
+	}
+	pos := sort.SearchStrings(idx.chunks[ci], prefix)
+	ret := 0
+	for ; ci < len(idx.chunks); ci++ {
+		chunk := idx.chunks[ci][pos:]
+		if strings.HasPrefix(chunk[len(chunk)-1], prefix) {
+			ret += len(chunk)
+			pos = 0
+			continue
+		}
+		return ret + sort.Search(len(chunk), func(i int) bool {
+			return !strings.HasPrefix(chunk[i], prefix)
+		})
+	}
+	return ret
+}
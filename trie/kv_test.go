@@ -1,6 +1,13 @@
 package trie
 
 import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/lunfardo314/verkle/kzg"
@@ -55,6 +62,337 @@ func TestPartition(t *testing.T) {
 	require.EqualValues(t, string(d), "klm")
 }
 
+func allKeys(kvs KVStore) []string {
+	ret := make([]string, 0)
+	kvs.IterateKeys(nil, func(k []byte) bool {
+		ret = append(ret, string(k))
+		return true
+	})
+	return ret
+}
+
+func TestNestedPartition(t *testing.T) {
+	kvs := NewSimpleKVStore()
+	p1 := kvs.Partition("1")
+	p12 := p1.Partition("2")
+	p12.Set([]byte("abc"), []byte("klm"))
+	p1.Set([]byte("abc"), []byte("xyz"))
+	kvs.Set([]byte("2abc"), []byte("other"))
+
+	d, ok := kvs.Get([]byte("12abc"))
+	require.True(t, ok)
+	require.EqualValues(t, "klm", string(d))
+	require.EqualValues(t, []string{"abc"}, allKeys(p12))
+	require.EqualValues(t, []string{"2abc", "abc"}, allKeys(p1))
+	require.EqualValues(t, 1, p12.Size())
+	require.EqualValues(t, 2, p1.Size())
+	require.EqualValues(t, 3, kvs.Size())
+	p12.Del([]byte("abc"))
+	require.EqualValues(t, 0, p12.Size())
+	require.EqualValues(t, 2, kvs.Size())
+}
+
+func TestIterate(t *testing.T) {
+	kvs := NewSimpleKVStore()
+	expected := make([]string, 0)
+	for i := 0; i < 5000; i++ {
+		k := fmt.Sprintf("%d", rand.Intn(100000))
+		if !kvs.Has([]byte(k)) {
+			expected = append(expected, k)
+		}
+		kvs.Set([]byte(k), []byte("v"+k))
+	}
+	for _, k := range expected[:1000] {
+		kvs.Del([]byte(k))
+	}
+	expected = expected[1000:]
+	sort.Strings(expected)
+	require.EqualValues(t, expected, allKeys(kvs))
+	require.EqualValues(t, len(expected), kvs.Size())
+
+	prefixed := make([]string, 0)
+	for _, k := range expected {
+		if strings.HasPrefix(k, "12") {
+			prefixed = append(prefixed, k[2:])
+		}
+	}
+	require.EqualValues(t, prefixed, allKeys(kvs.Partition("12")))
+	require.EqualValues(t, len(prefixed), kvs.Partition("12").Size())
+	for _, prefix := range []string{"", "1", "5", "99", "123", "x"} {
+		n := 0
+		for _, k := range expected {
+			if strings.HasPrefix(k, prefix) {
+				n++
+			}
+		}
+		require.EqualValues(t, n, kvs.Partition(prefix).Size())
+	}
+
+	n := 0
+	kvs.Iterate([]byte("12"), func(k, v []byte) bool {
+		require.EqualValues(t, "v"+string(k), string(v))
+		require.True(t, strings.HasPrefix(string(k), "12"))
+		n++
+		return n < 3
+	})
+	require.EqualValues(t, 3, n)
+	kvs.IterateKeys([]byte("x"), func([]byte) bool {
+		t.Fail()
+		return true
+	})
+}
+
+func TestFileKVStore(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "state.data")
+	kvs, err := OpenFileKVStore(fname)
+	require.NoError(t, err)
+
+	kvs.Set([]byte("abc"), []byte("klm"))
+	kvs.Set(nil, []byte("nil key"))
+	kvs.Set([]byte("empty"), []byte{})
+	kvs.Partition("1").Set([]byte("abc"), []byte("p1"))
+	d, ok := kvs.Get([]byte("abc"))
+	require.True(t, ok)
+	require.EqualValues(t, "klm", string(d))
+	kvs.Set([]byte("abc"), []byte("xyz"))
+	kvs.Set([]byte("del"), []byte("deleted"))
+	kvs.Del([]byte("del"))
+	require.False(t, kvs.Has([]byte("del")))
+	require.NoError(t, kvs.Close())
+
+	kvs, err = OpenFileKVStore(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, []string{"", "1abc", "abc", "empty"}, allKeys(kvs))
+	d, ok = kvs.Get([]byte("abc"))
+	require.True(t, ok)
+	require.EqualValues(t, "xyz", string(d))
+	d, ok = kvs.Get(nil)
+	require.True(t, ok)
+	require.EqualValues(t, "nil key", string(d))
+	d, ok = kvs.Get([]byte("empty"))
+	require.True(t, ok)
+	require.EqualValues(t, 0, len(d))
+	d, ok = kvs.Partition("1").Get([]byte("abc"))
+	require.True(t, ok)
+	require.EqualValues(t, "p1", string(d))
+	require.False(t, kvs.Has([]byte("del")))
+
+	t.Run("incomplete record", func(t *testing.T) {
+		kvs.Set([]byte("last"), []byte("incomplete"))
+		require.NoError(t, kvs.Close())
+		fi, err := os.Stat(fname)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(fname, fi.Size()-3))
+
+		kvs, err = OpenFileKVStore(fname)
+		require.NoError(t, err)
+		require.False(t, kvs.Has([]byte("last")))
+		require.EqualValues(t, 4, kvs.Size())
+		kvs.Set([]byte("last"), []byte("complete"))
+		require.NoError(t, kvs.Close())
+
+		kvs, err = OpenFileKVStore(fname)
+		require.NoError(t, err)
+		d, ok := kvs.Get([]byte("last"))
+		require.True(t, ok)
+		require.EqualValues(t, "complete", string(d))
+	})
+	t.Run("compaction", func(t *testing.T) {
+		value := make([]byte, 1000)
+		for i := 0; i < 3000; i++ {
+			kvs.Set([]byte(fmt.Sprintf("key%d", i%10)), value)
+		}
+		require.NoError(t, kvs.Flush())
+		fi, err := os.Stat(fname)
+		require.NoError(t, err)
+		require.True(t, fi.Size() < 100000)
+		require.EqualValues(t, 15, kvs.Size())
+		require.NoError(t, kvs.Close())
+
+		kvs, err = OpenFileKVStore(fname)
+		require.NoError(t, err)
+		require.EqualValues(t, 15, kvs.Size())
+		d, ok := kvs.Get([]byte("key7"))
+		require.True(t, ok)
+		require.EqualValues(t, value, d)
+		require.NoError(t, kvs.Close())
+	})
+}
+
+func TestFileKVStoreLimits(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "state.data")
+	kvs, err := OpenFileKVStore(fname)
+	require.NoError(t, err)
+	kvs.Set([]byte("a"), []byte("1"))
+	require.Panics(t, func() {
+		kvs.Set(make([]byte, 70000), []byte("2"))
+	})
+	b := kvs.Batch()
+	b.Set([]byte("b"), []byte("2"))
+	b.Set(make([]byte, MaxFileKVKeySize+1), nil)
+	require.Error(t, b.Commit())
+	kvs.Set([]byte("z"), []byte("3"))
+	require.NoError(t, kvs.Close())
+
+	kvs, err = OpenFileKVStore(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, []string{"a", "z"}, allKeys(kvs))
+	require.NoError(t, kvs.Close())
+}
+
+func TestFileKVStoreCorrupted(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "state.data")
+	kvs, err := OpenFileKVStore(fname)
+	require.NoError(t, err)
+	kvs.Set([]byte("a"), []byte("1111"))
+	kvs.Set([]byte("b"), []byte("2222"))
+	kvs.Set([]byte("c"), []byte("3333"))
+	require.NoError(t, kvs.Close())
+	data, err := ioutil.ReadFile(fname)
+	require.NoError(t, err)
+
+	// checksum error of the last record is the crash during the write
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1]++
+	require.NoError(t, ioutil.WriteFile(fname, corrupted, 0600))
+	kvs, err = OpenFileKVStore(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, []string{"a", "b"}, allKeys(kvs))
+	require.NoError(t, kvs.Close())
+
+	// so is the tail of zeros
+	require.NoError(t, ioutil.WriteFile(fname, append(append([]byte{}, data...), make([]byte, 100)...), 0600))
+	kvs, err = OpenFileKVStore(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, []string{"a", "b", "c"}, allKeys(kvs))
+	require.NoError(t, kvs.Close())
+
+	// the corrupted record followed by other records is an error
+	corrupted = append([]byte{}, data...)
+	corrupted[len(corrupted)/2]++
+	require.NoError(t, ioutil.WriteFile(fname, corrupted, 0600))
+	_, err = OpenFileKVStore(fname)
+	require.Error(t, err)
+	fi, err := os.Stat(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, len(data), fi.Size())
+}
+
+func TestBatch(t *testing.T) {
+	kvs := NewSimpleKVStore()
+	kvs.Set([]byte("del"), []byte("1"))
+	b := kvs.Batch()
+	b.Set([]byte("abc"), []byte("klm"))
+	b.Set([]byte("abc"), []byte("xyz"))
+	b.Del([]byte("del"))
+	require.True(t, kvs.Has([]byte("del")))
+	require.False(t, kvs.Has([]byte("abc")))
+	require.NoError(t, b.Commit())
+	require.EqualValues(t, []string{"abc"}, allKeys(kvs))
+	d, _ := kvs.Get([]byte("abc"))
+	require.EqualValues(t, "xyz", string(d))
+
+	b = kvs.Batch()
+	b.Partition("1").Partition("2").Set([]byte("abc"), []byte("p12"))
+	b.Partition("1").Del([]byte("x"))
+	require.NoError(t, b.Partition("1").Commit())
+	d, _ = kvs.Partition("1").Partition("2").Get([]byte("abc"))
+	require.EqualValues(t, "p12", string(d))
+
+	t.Run("file", func(t *testing.T) {
+		fname := filepath.Join(t.TempDir(), "state.data")
+		kvs, err := OpenFileKVStore(fname)
+		require.NoError(t, err)
+		kvs.Set([]byte("del"), []byte("1"))
+		b := kvs.Batch()
+		b.Set([]byte("abc"), []byte("klm"))
+		b.Del([]byte("del"))
+		b.Del(nil)
+		require.NoError(t, b.Commit())
+		fi, err := os.Stat(fname)
+		require.NoError(t, err)
+		sizeBefore := fi.Size()
+
+		b = kvs.Batch()
+		b.Set([]byte("abc"), []byte("xyz"))
+		b.Set([]byte("new"), []byte("new"))
+		require.NoError(t, b.Commit())
+		require.NoError(t, kvs.Close())
+
+		kvs, err = OpenFileKVStore(fname)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"abc", "new"}, allKeys(kvs))
+		d, _ := kvs.Get([]byte("abc"))
+		require.EqualValues(t, "xyz", string(d))
+		require.NoError(t, kvs.Close())
+
+		// the incomplete batch is discarded as a whole
+		fi, err = os.Stat(fname)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(fname, fi.Size()-1))
+		kvs, err = OpenFileKVStore(fname)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"abc"}, allKeys(kvs))
+		d, _ = kvs.Get([]byte("abc"))
+		require.EqualValues(t, "klm", string(d))
+		require.EqualValues(t, sizeBefore, kvs.size)
+		require.NoError(t, kvs.Close())
+	})
+}
+
+// kvStoreNoBatch hides Batch of the underlying store. The in-memory store has nothing to flush
+type kvStoreNoBatch struct {
+	KVStore
+}
+
+func (s *kvStoreNoBatch) Flush() error {
+	return nil
+}
+
+func TestWAL(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "wal")
+	_, err := NewWALKVStore(NewSimpleKVStore(), fname)
+	require.Error(t, err)
+	store := &kvStoreNoBatch{NewSimpleKVStore()}
+	wal, err := NewWALKVStore(store, fname)
+	require.NoError(t, err)
+	store.Set([]byte("del"), []byte("1"))
+	b := wal.Batch()
+	b.Set([]byte("abc"), []byte("klm"))
+	b.Del([]byte("del"))
+	require.NoError(t, b.Commit())
+	require.EqualValues(t, []string{"abc"}, allKeys(store))
+	fi, err := os.Stat(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, fi.Size())
+	require.NoError(t, wal.Close())
+
+	// crash after the batch is written to the log
+	record, _, _ := encodeFileKVRecord([]batchOp{
+		{key: []byte("abc"), value: []byte("xyz")},
+		{key: []byte("new"), value: []byte("new")},
+	})
+	require.NoError(t, ioutil.WriteFile(fname, record[:len(record)-1], 0600))
+	wal, err = NewWALKVStore(store, fname)
+	require.NoError(t, err)
+	require.EqualValues(t, []string{"abc"}, allKeys(store))
+	require.NoError(t, wal.Close())
+
+	require.NoError(t, ioutil.WriteFile(fname, record, 0600))
+	wal, err = NewWALKVStore(store, fname)
+	require.NoError(t, err)
+	require.EqualValues(t, []string{"abc", "new"}, allKeys(store))
+	d, _ := store.Get([]byte("abc"))
+	require.EqualValues(t, "xyz", string(d))
+	b = wal.Batch()
+	b.Set(make([]byte, MaxFileKVKeySize+1), nil)
+	require.Error(t, b.Commit())
+	fi, err = os.Stat(fname)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, fi.Size())
+	require.NoError(t, wal.Close())
+}
+
 func TestNode0(t *testing.T) {
 	suite := bn256.NewSuite()
 	zero := suite.G1().Point().Null()
@@ -63,7 +401,7 @@ func TestNode0(t *testing.T) {
 
 	t.Run("new node", func(t *testing.T) {
 		n := &Node{}
-		c := n.Commit(ts)
+		c := n.Commit(NewKZG(ts))
 		require.True(t, c.Equal(zero))
 	})
 	t.Run("new node marshal", func(t *testing.T) {
@@ -1,23 +1,120 @@
 package trie
 
-import (
-	"sort"
-	"strings"
-)
-
 // KVStore abstract interface only used in this trie implementation
 type KVStore interface {
 	Set(k []byte, v []byte)
 	Del(k []byte)
 	Get(k []byte) ([]byte, bool)
 	Has(k []byte) bool
+	// Partition returns the store of keys with the prefix. Keys of the partition are without the prefix
 	Partition(prefix string) KVStore
-	Keys() []string
+	// Iterate calls fn for each key with the prefix and its value in the lexicographic order of keys,
+	// until fn returns false. The store must not be modified by fn
+	Iterate(prefix []byte, fn func(k, v []byte) bool)
+	// IterateKeys is Iterate without values
+	IterateKeys(prefix []byte, fn func(k []byte) bool)
+	// Size returns number of keys in the store
 	Size() int
 }
 
+// Batch collects changes of the store. Commit writes all of them to the store atomically
+type Batch interface {
+	Set(k []byte, v []byte)
+	Del(k []byte)
+	// Partition returns the batch which collects changes of the partition with the prefix into this batch
+	Partition(prefix string) Batch
+	Commit() error
+}
+
+// Batcher is implemented by key/value stores which can write batches of changes atomically
+type Batcher interface {
+	Batch() Batch
+}
+
+type batchOp struct {
+	del   bool
+	key   []byte
+	value []byte
+}
+
+// batch collects operations in the order of calls and passes them to the commit function of the store
+type batch struct {
+	ops    []batchOp
+	commit func(ops []batchOp) error
+}
+
+func (b *batch) Set(k []byte, v []byte) {
+	op := batchOp{
+		key:   make([]byte, len(k)),
+		value: make([]byte, len(v)),
+	}
+	copy(op.key, k)
+	copy(op.value, v)
+	b.ops = append(b.ops, op)
+}
+
+func (b *batch) Del(k []byte) {
+	op := batchOp{
+		del: true,
+		key: make([]byte, len(k)),
+	}
+	copy(op.key, k)
+	b.ops = append(b.ops, op)
+}
+
+func (b *batch) Partition(prefix string) Batch {
+	return &batchPartition{
+		batch:  b,
+		prefix: prefix,
+	}
+}
+
+func (b *batch) Commit() error {
+	ops := b.ops
+	b.ops = nil
+	return b.commit(ops)
+}
+
+// batchPartition adds the prefix to keys of changes
+type batchPartition struct {
+	batch  Batch
+	prefix string
+}
+
+func (p *batchPartition) Set(k []byte, v []byte) {
+	p.batch.Set([]byte(p.prefix+string(k)), v)
+}
+
+func (p *batchPartition) Del(k []byte) {
+	p.batch.Del([]byte(p.prefix + string(k)))
+}
+
+func (p *batchPartition) Partition(prefix string) Batch {
+	return &batchPartition{
+		batch:  p.batch,
+		prefix: p.prefix + prefix,
+	}
+}
+
+// Commit commits the whole batch, not only changes of the partition
+func (p *batchPartition) Commit() error {
+	return p.batch.Commit()
+}
+
+// applyBatch writes operations to the store one by one
+func applyBatch(store KVStore, ops []batchOp) {
+	for _, op := range ops {
+		if op.del {
+			store.Del(op.key)
+		} else {
+			store.Set(op.key, op.value)
+		}
+	}
+}
+
 type kvStoreSimple struct {
 	store map[string][]byte
+	keys  keyIndex
 }
 
 func NewSimpleKVStore() *kvStoreSimple {
@@ -36,18 +133,22 @@ func (kvs *kvStoreSimple) Partition(prefix string) KVStore {
 func (kvs *kvStoreSimple) Set(k []byte, v []byte) {
 	t := make([]byte, len(v))
 	copy(t, v)
-	if len(k) == 0 {
-		kvs.store[""] = t
-	} else {
-		kvs.store[string(k)] = t
+	key := string(k)
+	if _, ok := kvs.store[key]; !ok {
+		kvs.keys.insert(key)
 	}
+	kvs.store[key] = t
 }
 
 func (kvs *kvStoreSimple) Del(k []byte) {
 	if len(k) == 0 {
 		return
 	}
+	if _, ok := kvs.store[string(k)]; !ok {
+		return
+	}
 	delete(kvs.store, string(k))
+	kvs.keys.remove(string(k))
 }
 
 // nil key always is present
@@ -69,26 +170,44 @@ func (kvs *kvStoreSimple) Has(k []byte) bool {
 	return ok
 }
 
-func (kvs *kvStoreSimple) Keys() []string {
-	ret := make([]string, len(kvs.store))
-	for k := range kvs.store {
-		ret = append(ret, k)
-	}
-	sort.Strings(ret)
-	return ret
+func (kvs *kvStoreSimple) Iterate(prefix []byte, fn func(k, v []byte) bool) {
+	kvs.keys.iterate(string(prefix), func(key string) bool {
+		return fn([]byte(key), kvs.store[key])
+	})
+}
+
+func (kvs *kvStoreSimple) IterateKeys(prefix []byte, fn func(k []byte) bool) {
+	kvs.keys.iterate(string(prefix), func(key string) bool {
+		return fn([]byte(key))
+	})
 }
 
 func (kvs *kvStoreSimple) Size() int {
 	return len(kvs.store)
 }
 
+func (kvs *kvStoreSimple) countPrefix(prefix string) int {
+	return kvs.keys.count(prefix)
+}
+
+// Batch of the in-memory store is applied at once by Commit
+func (kvs *kvStoreSimple) Batch() Batch {
+	return &batch{commit: func(ops []batchOp) error {
+		applyBatch(kvs, ops)
+		return nil
+	}}
+}
+
 type partition struct {
 	store  KVStore
 	prefix string
 }
 
-func (kvs *partition) Partition(prefix string) KVStore {
-	panic("implement me")
+func (p *partition) Partition(prefix string) KVStore {
+	return &partition{
+		store:  p.store,
+		prefix: p.prefix + prefix,
+	}
 }
 
 func (p *partition) Set(k []byte, v []byte) {
@@ -111,24 +230,33 @@ func (p *partition) Has(k []byte) bool {
 	return p.store.Has(key)
 }
 
-func (p *partition) Keys() []string {
-	ret := make([]string, 0)
-	for _, k := range p.store.Keys() {
-		if strings.HasPrefix(k, p.prefix) {
-			ret = append(ret, k[len(p.prefix):])
-		}
-	}
-	sort.Strings(ret)
-	return ret
+func (p *partition) Iterate(prefix []byte, fn func(k, v []byte) bool) {
+	p.store.Iterate([]byte(p.prefix+string(prefix)), func(k, v []byte) bool {
+		return fn(k[len(p.prefix):], v)
+	})
+}
+
+func (p *partition) IterateKeys(prefix []byte, fn func(k []byte) bool) {
+	p.store.IterateKeys([]byte(p.prefix+string(prefix)), func(k []byte) bool {
+		return fn(k[len(p.prefix):])
+	})
+}
 
+// prefixCounter is implemented by stores which count keys with the prefix in their ordered index
+type prefixCounter interface {
+	countPrefix(prefix string) int
 }
 
+// Size counts keys of the partition with the ordered index of the store in O(log(n)+k/256),
+// where k is the size of the partition. For other stores it is a scan of keys of the partition
 func (p *partition) Size() int {
-	var ret int
-	for _, k := range p.store.Keys() {
-		if strings.HasPrefix(k, p.prefix) {
-			ret++
-		}
+	if c, ok := p.store.(prefixCounter); ok {
+		return c.countPrefix(p.prefix)
 	}
+	var ret int
+	p.IterateKeys(nil, func([]byte) bool {
+		ret++
+		return true
+	})
 	return ret
 }
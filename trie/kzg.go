@@ -0,0 +1,129 @@
+package trie
+
+import (
+	"github.com/lunfardo314/verkle/kzg"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+// KZG is the default vector commitment scheme of the trie, based on the KZG trusted setup.
+// Proofs are points of G1. It implements all optional features of the scheme
+type KZG struct {
+	ts       *kzg.TrustedSetup
+	verifier *KZGVerifier
+}
+
+// KZGVerifier verifies proofs of the KZG scheme with the verifier key of the trusted setup
+type KZGVerifier struct {
+	vk *kzg.VerifierKey
+}
+
+// NewKZG creates the KZG scheme with the trusted setup. The setup must be of degree 257
+func NewKZG(ts *kzg.TrustedSetup) *KZG {
+	assert(ts.D == 257, "trusted setup of degree 257 expected")
+	return &KZG{
+		ts:       ts,
+		verifier: NewKZGVerifier(ts.VerifierKey()),
+	}
+}
+
+// NewKZGVerifier creates the verifier of the KZG scheme with the verifier key
+func NewKZGVerifier(vk *kzg.VerifierKey) *KZGVerifier {
+	return &KZGVerifier{vk: vk}
+}
+
+func (k *KZG) TrustedSetup() *kzg.TrustedSetup {
+	return k.ts
+}
+
+func (k *KZG) Suite() *bn256.Suite {
+	return k.ts.Suite
+}
+
+func (k *KZG) Commit(vect []kyber.Scalar) kyber.Point {
+	return k.ts.Commit(vect)
+}
+
+func (k *KZG) CommitDelta(i int, delta kyber.Scalar) kyber.Point {
+	return k.ts.Suite.G1().Point().Mul(delta, k.ts.LagrangeBasis[i])
+}
+
+func (k *KZG) Prove(vect []kyber.Scalar, i int) OpeningProof {
+	return k.ts.Prove(vect, i)
+}
+
+func (k *KZG) Verifier() Verifier {
+	return k.verifier
+}
+
+func (k *KZG) Bytes() []byte {
+	return k.ts.Bytes()
+}
+
+func (k *KZG) SupportsHiding() bool {
+	return k.ts.SupportsHiding()
+}
+
+func (k *KZG) CommitBlinding(blinding []kyber.Scalar) kyber.Point {
+	return k.ts.CommitBlinding(blinding)
+}
+
+func (k *KZG) ProveHiding(vect, blinding []kyber.Scalar, i int) OpeningProof {
+	return k.ts.ProveHiding(vect, blinding, i)
+}
+
+func (k *KZG) UpdateProof(pi OpeningProof, i, j int, delta kyber.Scalar) OpeningProof {
+	return k.ts.UpdateProof(pi.(kyber.Point), i, j, delta)
+}
+
+func (k *KZG) ProveAll(vect []kyber.Scalar) []OpeningProof {
+	proofs := k.ts.ProveAll(vect)
+	ret := make([]OpeningProof, len(proofs))
+	for i, pi := range proofs {
+		ret[i] = pi
+	}
+	return ret
+}
+
+func (k *KZG) ProveAggregated(commitments []kyber.Point, vects [][]kyber.Scalar, indices []int) AggregatedProof {
+	return k.ts.ProveAggregated(commitments, vects, indices)
+}
+
+func (v *KZGVerifier) VerifierKey() *kzg.VerifierKey {
+	return v.vk
+}
+
+func (v *KZGVerifier) Suite() *bn256.Suite {
+	return v.vk.Suite
+}
+
+func (v *KZGVerifier) Verify(c kyber.Point, proof OpeningProof, value kyber.Scalar, i int) bool {
+	pi, ok := proof.(kyber.Point)
+	return ok && v.vk.Verify(c, pi, value, i)
+}
+
+func (v *KZGVerifier) Unblind(c kyber.Point, blindingValue kyber.Scalar) kyber.Point {
+	return v.vk.Unblind(c, blindingValue)
+}
+
+func (v *KZGVerifier) BatchVerify(commitments []kyber.Point, proofs []OpeningProof, values []kyber.Scalar, indices []int) bool {
+	items := make([]kzg.Opening, len(proofs))
+	for i := range items {
+		pi, ok := proofs[i].(kyber.Point)
+		if !ok {
+			return false
+		}
+		items[i] = kzg.Opening{
+			C:     commitments[i],
+			Pi:    pi,
+			Value: values[i],
+			Index: indices[i],
+		}
+	}
+	return v.vk.BatchVerify(items)
+}
+
+func (v *KZGVerifier) VerifyAggregated(commitments []kyber.Point, indices []int, values []kyber.Scalar, proof AggregatedProof) bool {
+	pr, ok := proof.(*kzg.AggregatedProof)
+	return ok && pr != nil && v.vk.VerifyAggregated(commitments, indices, values, pr)
+}
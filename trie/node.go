@@ -2,10 +2,11 @@ package trie
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"io"
 
-	"github.com/lunfardo314/verkle/kzg"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"golang.org/x/crypto/blake2b"
@@ -16,12 +17,14 @@ type Node struct {
 	pathFragment  []byte
 	children      [256]kyber.Point
 	terminalValue kyber.Scalar
+	// blindingSeed is not nil for hidden nodes. The blinding vector of the hiding commitment is derived from it
+	blindingSeed []byte
 }
 
 // NodeFromBytes
 func (st *State) NodeFromBytes(data []byte) (*Node, error) {
 	ret := &Node{}
-	if err := ret.read(bytes.NewReader(data), st.ts.Suite); err != nil {
+	if err := ret.read(bytes.NewReader(data), st.vc.Suite()); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -33,6 +36,7 @@ func (n *Node) Clone() *Node {
 		pathFragment:  n.pathFragment,
 		children:      n.children,
 		terminalValue: n.terminalValue.Clone(),
+		blindingSeed:  n.blindingSeed,
 	}
 }
 
@@ -45,15 +49,62 @@ func (n *Node) Bytes() []byte {
 	return buf.Bytes()
 }
 
-// Commit calculates commitment of the node from child commitments
-func (n *Node) Commit(ts *kzg.TrustedSetup) kyber.Point {
+// Commit calculates commitment of the node from child commitments and the path fragment
+// It is a vector commitment plus commitment to the path fragment: C = [f(s)]1 + h(pathFragment)*Q
+// The vector commitment of the hidden node is hiding: C = [f(s)]1 + r(s)*H + h(pathFragment)*Q
+func (n *Node) Commit(vc VectorCommitment) kyber.Point {
 	var vect [257]kyber.Scalar
-	n.Vector(ts, &vect)
-	return ts.Commit(vect[:])
+	n.Vector(vc.Suite(), &vect)
+	ret := vc.Commit(vect[:])
+	if blinding := n.blinding(vc.Suite()); blinding != nil {
+		ret.Add(ret, hidingCommitment(vc).CommitBlinding(blinding))
+	}
+	return ret.Add(ret, pathFragmentCommitment(vc.Suite(), n.pathFragment))
+}
+
+// blinding returns blinding vector of the hidden node, derived from the blinding seed. nil if the node is not hidden
+func (n *Node) blinding(suite *bn256.Suite) []kyber.Scalar {
+	if n.blindingSeed == nil {
+		return nil
+	}
+	ret := make([]kyber.Scalar, 257)
+	var buf [blindingSeedSize + 2]byte
+	copy(buf[:], n.blindingSeed)
+	for i := range ret {
+		binary.LittleEndian.PutUint16(buf[blindingSeedSize:], uint16(i))
+		ret[i] = scalarFromBytes(suite.G1().Scalar(), buf[:])
+	}
+	return ret
+}
+
+func newBlindingSeed() []byte {
+	ret := make([]byte, blindingSeedSize)
+	if _, err := rand.Read(ret); err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// pathFragmentBase is a generator of G1 used to commit to path fragments.
+// It is obtained by hashing to the curve, so nobody knows its discrete logarithm
+// with respect to the Lagrange basis of the trusted setup
+var pathFragmentBase = bn256.NewSuite().G1().Point().(hashablePoint).Hash([]byte("verkle trie path fragment"))
+
+type hashablePoint interface {
+	Hash(data []byte) kyber.Point
+}
+
+// pathFragmentCommitment returns h(pathFragment)*Q. Empty path fragment is committed to zero point
+func pathFragmentCommitment(suite *bn256.Suite, pathFragment []byte) kyber.Point {
+	if len(pathFragment) == 0 {
+		return suite.G1().Point().Null()
+	}
+	h := scalarFromBytes(suite.G1().Scalar(), pathFragment)
+	return suite.G1().Point().Mul(h, pathFragmentBase)
 }
 
 // Vector extracts vector from the node
-func (n *Node) Vector(ts *kzg.TrustedSetup, ret *[257]kyber.Scalar) {
+func (n *Node) Vector(suite *bn256.Suite, ret *[257]kyber.Scalar) {
 	for i, p := range n.children {
 		if p == nil {
 			continue
@@ -63,24 +114,37 @@ func (n *Node) Vector(ts *kzg.TrustedSetup, ret *[257]kyber.Scalar) {
 			panic(err)
 		}
 		h := blake2b.Sum256(d)
-		ret[i] = ts.Suite.G1().Scalar().SetBytes(h[:])
+		ret[i] = suite.G1().Scalar().SetBytes(h[:])
 	}
 	ret[256] = n.terminalValue
 }
 
 // proofSpot calculates proof that the vector of the node has certain value at the position i
-func (n *Node) proofSpot(ts *kzg.TrustedSetup, i int) (kyber.Point, kyber.Scalar) {
+func (n *Node) proofSpot(vc VectorCommitment, i int) (OpeningProof, kyber.Scalar) {
 	i = i % 257
 	var vect [257]kyber.Scalar
-	n.Vector(ts, &vect)
-	return ts.Prove(vect[:], i), vect[i]
+	n.Vector(vc.Suite(), &vect)
+	if blinding := n.blinding(vc.Suite()); blinding != nil {
+		return hidingCommitment(vc).ProveHiding(vect[:], blinding, i), vect[i]
+	}
+	return vc.Prove(vect[:], i), vect[i]
+}
+
+// hidingCommitment returns the scheme as HidingCommitment. The hidden node can't be committed or proved otherwise
+func hidingCommitment(vc VectorCommitment) HidingCommitment {
+	ret, ok := vc.(HidingCommitment)
+	assert(ok && ret.SupportsHiding(), "hidden node: vector commitment scheme does not support hiding commitments")
+	return ret
 }
 
 const (
 	hasTerminalValueFlag = 0x01
 	hasChildrenFlag      = 0x02
+	hasBlindingFlag      = 0x04
 )
 
+const blindingSeedSize = 32
+
 func (n *Node) write(w io.Writer) error {
 	assert(len(n.pathFragment) < 256, "len(n.pathFragment)<256")
 	if _, err := w.Write([]byte{byte(len(n.pathFragment))}); err != nil {
@@ -93,6 +157,9 @@ func (n *Node) write(w io.Writer) error {
 	if n.terminalValue != nil {
 		smallFlags = hasTerminalValueFlag
 	}
+	if n.blindingSeed != nil {
+		smallFlags |= hasBlindingFlag
+	}
 	// compress children flags
 	var flags [32]byte
 	for i, v := range n.children {
@@ -111,6 +178,11 @@ func (n *Node) write(w io.Writer) error {
 			return err
 		}
 	}
+	if smallFlags&hasBlindingFlag != 0 {
+		if _, err := w.Write(n.blindingSeed); err != nil {
+			return err
+		}
+	}
 	if smallFlags&hasChildrenFlag != 0 {
 		if _, err := w.Write(flags[:]); err != nil {
 			return err
@@ -149,6 +221,14 @@ func (n *Node) read(r io.Reader, suite *bn256.Suite) error {
 	} else {
 		n.terminalValue = nil
 	}
+	if smallFlags&hasBlindingFlag != 0 {
+		n.blindingSeed = make([]byte, blindingSeedSize)
+		if _, err := io.ReadFull(r, n.blindingSeed); err != nil {
+			return err
+		}
+	} else {
+		n.blindingSeed = nil
+	}
 	if smallFlags&hasChildrenFlag != 0 {
 		var flags [32]byte
 		if _, err := r.Read(flags[:]); err != nil {
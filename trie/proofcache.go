@@ -0,0 +1,99 @@
+package trie
+
+import (
+	"go.dedis.ch/kyber/v3"
+)
+
+// EnableProofCache enables or disables cache of proofs of node vectors.
+// With the cache enabled, Prove computes the proof of each node and position only once.
+// If the vector commitment scheme implements ProofUpdater, cached proofs are kept valid across Update and Delete calls
+// (for KZG, with one multiplication on the curve per cached proof of each node along the updated path),
+// instead of proving from scratch upon each call to Prove. Otherwise cached proofs of the node are dropped when it changes.
+// It pays off when same keys are proved repeatedly while the state changes slowly.
+// Disabling the cache drops all cached proofs
+func (st *State) EnableProofCache(enable bool) {
+	if !enable {
+		st.proofCache = nil
+		return
+	}
+	if st.proofCache == nil {
+		st.proofCache = make(map[string]map[int]OpeningProof)
+	}
+}
+
+// nodeProof returns proof of the position index of the vector of the node with the key.
+// Cached proofs of type kyber.Point are returned as copies, so the caller can't corrupt the cache
+func (st *State) nodeProof(key []byte, node *Node, index int) OpeningProof {
+	if st.proofCache == nil {
+		ret, _ := node.proofSpot(st.vc, index)
+		return ret
+	}
+	proofs, ok := st.proofCache[string(key)]
+	if !ok {
+		proofs = make(map[int]OpeningProof)
+		st.proofCache[string(key)] = proofs
+	}
+	if ret, ok := proofs[index]; ok {
+		return cloneProof(ret)
+	}
+	ret, _ := node.proofSpot(st.vc, index)
+	proofs[index] = ret
+	return cloneProof(ret)
+}
+
+func cloneProof(pi OpeningProof) OpeningProof {
+	if p, ok := pi.(kyber.Point); ok {
+		return p.Clone()
+	}
+	return pi
+}
+
+// updateCachedProofs updates cached proofs of the node with the key after the position j of the vector has been changed by delta
+func (st *State) updateCachedProofs(key []byte, j int, delta kyber.Scalar) {
+	if st.proofCache == nil {
+		return
+	}
+	updater, ok := st.vc.(ProofUpdater)
+	if !ok {
+		st.invalidateCachedProofs(key)
+		return
+	}
+	for i, pi := range st.proofCache[string(key)] {
+		st.proofCache[string(key)][i] = updater.UpdateProof(pi, i, j, delta)
+	}
+}
+
+// invalidateCachedProofs drops cached proofs of the node with the key. It is used when the vector of the node
+// is replaced, not just updated
+func (st *State) invalidateCachedProofs(key []byte) {
+	if st.proofCache == nil {
+		return
+	}
+	delete(st.proofCache, string(key))
+}
+
+// CacheNodeProofs calculates proofs of all positions of the vector of the node with the key
+// at once if the scheme implements ProofUpdater (see kzg.ProveAll), otherwise one by one,
+// and puts them into the proof cache. It is used to precalculate proofs of hot nodes, such as the root node.
+// The proof cache is enabled if it is disabled. Returns false if the node does not exist
+func (st *State) CacheNodeProofs(key []byte) bool {
+	node, ok := st.GetNode(key)
+	if !ok {
+		return false
+	}
+	st.EnableProofCache(true)
+	proofs := make(map[int]OpeningProof)
+	if updater, ok := st.vc.(ProofUpdater); ok && node.blindingSeed == nil {
+		var vect [257]kyber.Scalar
+		node.Vector(st.vc.Suite(), &vect)
+		for i, pi := range updater.ProveAll(vect[:]) {
+			proofs[i] = pi
+		}
+	} else {
+		for i := 0; i < 257; i++ {
+			proofs[i], _ = node.proofSpot(st.vc, i)
+		}
+	}
+	st.proofCache[string(key)] = proofs
+	return true
+}
@@ -1,28 +1,94 @@
 package trie
 
 import (
-	"github.com/lunfardo314/verkle/kzg"
+	"bytes"
+
 	"go.dedis.ch/kyber/v3"
 	"golang.org/x/xerrors"
 )
 
 type ProofElement struct {
-	C     kyber.Point // vector (node) commitment
-	Index int
-	Proof kyber.Point
+	C            kyber.Point // node commitment, i.e. vector commitment plus commitment to the path fragment
+	PathFragment []byte
+	Index        int
+	Proof        OpeningProof
+	// Blinding is the value of the blinding vector at Index if the node is hidden, otherwise nil
+	Blinding kyber.Scalar
 }
 
 type Proof struct {
 	Key   []byte
 	Value []byte
 	Path  []*ProofElement
+	// TerminalCommitment is only used in the proof of absence, which ends in the node with the path fragment
+	// diverging from the key. It is the commitment to the terminal value of that node, nil if there's no terminal value
+	TerminalCommitment kyber.Scalar
+	// Aggregated is not nil if openings of all elements of the path are aggregated into one proof.
+	// In that case elements of the path do not contain proofs
+	Aggregated AggregatedProof
 }
 
 // Prove return a valid proof.
 // If the key is present in the state, it contains the proof of presence of it in the key
 // If the key is absent, the field Value == nil and the proof is a prove of commitment to 0 value
-// in the last element of the path
+// in the last element of the path.
+// If the key diverges from the path fragment of the node or ends inside it, the proof of absence
+// reveals that path fragment by opening the terminal value of the node
 func (st *State) Prove(key []byte) (*Proof, bool) {
+	ret, nodes := st.proofPath(key)
+	nodeKey := make([]byte, 0, len(key)+1)
+	for i, p := range ret.Path {
+		p.Proof = st.nodeProof(nodeKey, nodes[i], p.Index)
+		if blinding := nodes[i].blinding(st.vc.Suite()); blinding != nil {
+			p.Blinding = blinding[p.Index]
+		}
+		nodeKey = append(nodeKey, p.PathFragment...)
+		nodeKey = append(nodeKey, byte(p.Index))
+	}
+	return ret, !ret.IsProofOfAbsence()
+}
+
+// ProveStr prove
+func (st *State) ProveStr(key string) (*Proof, bool) {
+	return st.Prove([]byte(key))
+}
+
+// ProveAggregated returns the same proof as Prove, however openings along the path are aggregated
+// into one compact proof, which is verified with constant number of pairings.
+// Openings are aggregated only if the vector commitment scheme implements Aggregator.
+// Openings of hidden nodes are not aggregated: if the path contains hidden nodes, the proof is the same as of Prove
+func (st *State) ProveAggregated(key []byte) (*Proof, bool) {
+	aggregator, ok := st.vc.(Aggregator)
+	if !ok {
+		return st.Prove(key)
+	}
+	ret, nodes := st.proofPath(key)
+	for _, n := range nodes {
+		if n.blindingSeed != nil {
+			return st.Prove(key)
+		}
+	}
+	commitments := make([]kyber.Point, len(ret.Path))
+	vects := make([][]kyber.Scalar, len(ret.Path))
+	indices := make([]int, len(ret.Path))
+	for i, p := range ret.Path {
+		var vect [257]kyber.Scalar
+		nodes[i].Vector(st.vc.Suite(), &vect)
+		vects[i] = vect[:]
+		commitments[i] = st.vc.Suite().G1().Point().Sub(p.C, pathFragmentCommitment(st.vc.Suite(), p.PathFragment))
+		indices[i] = p.Index
+	}
+	ret.Aggregated = aggregator.ProveAggregated(commitments, vects, indices)
+	return ret, !ret.IsProofOfAbsence()
+}
+
+// ProveAggregatedStr prove
+func (st *State) ProveAggregatedStr(key string) (*Proof, bool) {
+	return st.ProveAggregated([]byte(key))
+}
+
+// proofPath returns proof without KZG proofs and nodes along the path of the proof
+func (st *State) proofPath(key []byte) (*Proof, []*Node) {
 	value, ok := st.values.Get(key)
 	if !ok {
 		// value does not exists in the state
@@ -34,23 +100,32 @@ func (st *State) Prove(key []byte) (*Proof, bool) {
 		Value: value,
 		Path:  make([]*ProofElement, 0),
 	}
-	rootC := st.ts.Suite.G1().Point()
+	rootC := st.vc.Suite().G1().Point()
 	st.RootCommitment(rootC)
-	st.mustProofPath(key, 0, rootC, ret)
-	return ret, !ret.IsProofOfAbsence()
-}
-
-// ProveStr prove
-func (st *State) ProveStr(key string) (*Proof, bool) {
-	return st.Prove([]byte(key))
+	nodes := make([]*Node, 0)
+	st.mustProofPath(key, 0, rootC, ret, &nodes)
+	return ret, nodes
 }
 
 // always succeeds if the key is present in the state
-func (st *State) mustProofPath(path []byte, pathPosition uint16, c kyber.Point, proof *Proof) {
+func (st *State) mustProofPath(path []byte, pathPosition uint16, c kyber.Point, proof *Proof, nodes *[]*Node) {
 	assert(int(pathPosition) <= len(path), "pathPosition <=len(path)")
 	node, ok := st.GetNode(path[:pathPosition])
 	assert(ok, "inconsistency 1")
+	*nodes = append(*nodes, node)
 
+	if !bytes.HasPrefix(path[pathPosition:], node.pathFragment) {
+		// the key diverges inside the path fragment. The proof is about absence of the key in the state
+		proof.Path = append(proof.Path, &ProofElement{
+			C:            c,
+			PathFragment: node.pathFragment,
+			Index:        256,
+		})
+		if node.terminalValue != nil {
+			proof.TerminalCommitment = node.terminalValue.Clone()
+		}
+		return
+	}
 	pathPosition += uint16(len(node.pathFragment))
 	assert(int(pathPosition) <= len(path), "int(pathPosition)<=len(path)")
 
@@ -61,11 +136,10 @@ func (st *State) mustProofPath(path []byte, pathPosition uint16, c kyber.Point,
 		childIdx = int(path[pathPosition])
 	}
 
-	pi, _ := node.proofSpot(st.ts, childIdx)
 	ret := &ProofElement{
-		C:     c,
-		Index: childIdx,
-		Proof: pi,
+		C:            c,
+		PathFragment: node.pathFragment,
+		Index:        childIdx,
 	}
 	proof.Path = append(proof.Path, ret)
 	var absence bool
@@ -81,7 +155,7 @@ func (st *State) mustProofPath(path []byte, pathPosition uint16, c kyber.Point,
 	}
 	if int(pathPosition) < len(path) {
 		assert(childIdx < len(node.children), "childIdx<len(node.children)")
-		st.mustProofPath(path, pathPosition+1, node.children[childIdx], proof)
+		st.mustProofPath(path, pathPosition+1, node.children[childIdx], proof, nodes)
 	} else {
 		assert(int(pathPosition) == len(path), "int(pathPosition) == len(path)")
 	}
@@ -99,23 +173,144 @@ func (pr *Proof) RootCommitment(ret kyber.Point) {
 	ret.Set(pr.Path[0].C)
 }
 
-func VerifyProof(ts *kzg.TrustedSetup, proof *Proof) error {
-	v := ts.Suite.G1().Scalar()
-	for i := 0; i < len(proof.Path); i++ {
-		if i == len(proof.Path)-1 {
-			if proof.Value != nil {
-				scalarFromBytes(v, proof.Value)
+// VerifyProof verifies the proof against the root commitment in the first element of the path.
+// Only the verifier of the vector commitment scheme is needed for that. For KZG it is created from
+// the verifier key of the trusted setup (see NewKZGVerifier and kzg.TrustedSetup.VerifierKey).
+// The path must follow the key: path fragments and child indices are checked against the key,
+// so the proof is only valid for the key it contains.
+// The proof of absence ends either in the absent child or terminal value, or in the node with the path fragment
+// diverging from the key
+func VerifyProof(v Verifier, proof *Proof) error {
+	commitments, indices, values, err := proof.openings(v)
+	if err != nil {
+		return err
+	}
+	if proof.Aggregated != nil {
+		return verifyAggregated(v, commitments, indices, values, proof.Aggregated)
+	}
+	for i, p := range proof.Path {
+		if p.Proof == nil || !v.Verify(commitments[i], p.Proof, values[i], indices[i]) {
+			return xerrors.Errorf("proof invalid at path position %d", i)
+		}
+	}
+	return nil
+}
+
+// VerifyProofs verifies many proofs at once. If the verifier implements BatchVerifier, openings of all
+// not aggregated proofs are checked with one batch verification. For KZG it costs one pairing product check
+// instead of one check per element of each path.
+// The error does not indicate which proof is invalid
+func VerifyProofs(v Verifier, proofs ...*Proof) error {
+	batchVerifier, batch := v.(BatchVerifier)
+	var commitmentsBatch []kyber.Point
+	var proofsBatch []OpeningProof
+	var valuesBatch []kyber.Scalar
+	var indicesBatch []int
+	for i, proof := range proofs {
+		commitments, indices, values, err := proof.openings(v)
+		if err != nil {
+			return xerrors.Errorf("proof %d: %w", i, err)
+		}
+		if proof.Aggregated != nil {
+			if err = verifyAggregated(v, commitments, indices, values, proof.Aggregated); err != nil {
+				return xerrors.Errorf("proof %d: %w", i, err)
+			}
+			continue
+		}
+		for j, p := range proof.Path {
+			if p.Proof == nil {
+				return xerrors.Errorf("proof %d: missing proof at path position %d", i, j)
+			}
+			if !batch {
+				if !v.Verify(commitments[j], p.Proof, values[j], indices[j]) {
+					return xerrors.Errorf("proof %d: proof invalid at path position %d", i, j)
+				}
+				continue
+			}
+			commitmentsBatch = append(commitmentsBatch, commitments[j])
+			proofsBatch = append(proofsBatch, p.Proof)
+			valuesBatch = append(valuesBatch, values[j])
+			indicesBatch = append(indicesBatch, indices[j])
+		}
+	}
+	if batch && !batchVerifier.BatchVerify(commitmentsBatch, proofsBatch, valuesBatch, indicesBatch) {
+		return xerrors.New("batch verification failed")
+	}
+	return nil
+}
+
+func verifyAggregated(v Verifier, commitments []kyber.Point, indices []int, values []kyber.Scalar, proof AggregatedProof) error {
+	aggregateVerifier, ok := v.(AggregateVerifier)
+	if !ok {
+		return xerrors.New("verifier does not support aggregated proofs")
+	}
+	if !aggregateVerifier.VerifyAggregated(commitments, indices, values, proof) {
+		return xerrors.New("aggregated proof invalid")
+	}
+	return nil
+}
+
+// openings checks if the path follows the key and returns openings (vector commitment, index, value)
+// along the path of the proof
+func (pr *Proof) openings(v Verifier) ([]kyber.Point, []int, []kyber.Scalar, error) {
+	if len(pr.Path) == 0 {
+		return nil, nil, nil, xerrors.New("proof path is empty")
+	}
+	commitments := make([]kyber.Point, len(pr.Path))
+	indices := make([]int, len(pr.Path))
+	values := make([]kyber.Scalar, len(pr.Path))
+	pos := 0 // position in the key
+	for i, p := range pr.Path {
+		last := i == len(pr.Path)-1
+		diverges := !bytes.HasPrefix(pr.Key[pos:], p.PathFragment)
+		if !diverges {
+			pos += len(p.PathFragment)
+		}
+		value := v.Suite().G1().Scalar()
+		switch {
+		case !last:
+			if diverges || pos >= len(pr.Key) || p.Index != int(pr.Key[pos]) {
+				return nil, nil, nil, xerrors.Errorf("path does not follow the key at path position %d", i)
+			}
+			pos++
+			scalarFromPoint(value, pr.Path[i+1].C)
+		case diverges:
+			// proof of absence: the key diverges from the path fragment of the last node
+			if pr.Value != nil || p.Index != 256 {
+				return nil, nil, nil, xerrors.New("wrong proof of absence with diverging path fragment")
+			}
+			if pr.TerminalCommitment != nil {
+				value.Set(pr.TerminalCommitment)
+			} else {
+				value.Zero()
+			}
+		case p.Index == 256:
+			if pos != len(pr.Key) {
+				return nil, nil, nil, xerrors.New("path does not follow the key at the terminal value")
+			}
+			if pr.Value != nil {
+				scalarFromBytes(value, pr.Value)
 			} else {
 				// proving absence
-				v = ts.ZeroG1
+				value.Zero()
 			}
-		} else {
-			scalarFromPoint(v, proof.Path[i+1].C)
+		default:
+			// proof of absence: the child is absent
+			if pr.Value != nil || pos >= len(pr.Key) || p.Index != int(pr.Key[pos]) {
+				return nil, nil, nil, xerrors.New("wrong proof of absence of the child")
+			}
+			value.Zero()
 		}
-		p := proof.Path[i]
-		if !ts.Verify(p.C, p.Proof, v, p.Index) {
-			return xerrors.Errorf("proof invalid at path position %d", i)
+		commitments[i] = v.Suite().G1().Point().Sub(p.C, pathFragmentCommitment(v.Suite(), p.PathFragment))
+		if p.Blinding != nil {
+			hv, ok := v.(HidingVerifier)
+			if !ok {
+				return nil, nil, nil, xerrors.Errorf("verifier does not support hidden nodes at path position %d", i)
+			}
+			commitments[i] = hv.Unblind(commitments[i], p.Blinding)
 		}
+		indices[i] = p.Index
+		values[i] = value
 	}
-	return nil
+	return commitments, indices, values, nil
 }
@@ -3,6 +3,7 @@ package trie
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/lunfardo314/verkle/kzg"
 	"go.dedis.ch/kyber/v3"
@@ -12,46 +13,143 @@ import (
 
 // State represents kv store plus trie
 type State struct {
-	ts                  *kzg.TrustedSetup
+	vc                  VectorCommitment
 	store               KVStore
 	values              KVStore
 	trie                KVStore
 	root                KVStore
+	hidden              KVStore
 	rootCommitmentCache kyber.Point
 	valueCache          map[string][]byte
 	nodeCache           map[string]*Node
+	// proofCache contains proofs of the node vectors by node key and index. nil if disabled
+	proofCache map[string]map[int]OpeningProof
+	// hiddenPrefixes are prefixes of hidden keys
+	hiddenPrefixes [][]byte
+	// hiddenCache are prefixes hidden since the last flush
+	hiddenCache [][]byte
 }
 
 const (
 	prefixValues         = "v"
 	prefixTrie           = "t"
 	prefixRootCommitment = "r"
+	prefixHidden         = "h"
 )
 
+// NewState creates empty state with the KZG vector commitment scheme of the trusted setup
 func NewState(ts *kzg.TrustedSetup) *State {
-	store := NewSimpleKVStore()
-	ret := &State{
-		ts:                  ts,
+	return NewStateWithCommitment(NewKZG(ts))
+}
+
+// NewStateWithCommitment creates empty state with the vector commitment scheme
+func NewStateWithCommitment(vc VectorCommitment) *State {
+	ret := newState(vc, NewSimpleKVStore())
+	ret.init()
+	return ret
+}
+
+// NewStateWithStore creates empty state with the KZG vector commitment scheme in the store provided by the caller.
+// The store must be empty and must implement Batcher, so that FlushCaches is atomic.
+// A store without atomic batches can be wrapped with NewWALKVStore
+func NewStateWithStore(ts *kzg.TrustedSetup, store KVStore) (*State, error) {
+	return NewStateWithCommitmentAndStore(NewKZG(ts), store)
+}
+
+// NewStateWithCommitmentAndStore creates empty state with the vector commitment scheme in the store provided
+// by the caller. The store must be empty and must implement Batcher
+func NewStateWithCommitmentAndStore(vc VectorCommitment, store KVStore) (*State, error) {
+	if err := checkBatcher(store); err != nil {
+		return nil, err
+	}
+	if store.Size() != 0 {
+		return nil, xerrors.New("store is not empty")
+	}
+	ret := newState(vc, store)
+	ret.init()
+	return ret, nil
+}
+
+// OpenState opens the existing state with the KZG vector commitment scheme in the store.
+// The store must implement Batcher (see NewStateWithStore)
+func OpenState(ts *kzg.TrustedSetup, store KVStore) (*State, error) {
+	return OpenStateWithCommitment(NewKZG(ts), store)
+}
+
+// OpenStateWithCommitment opens the existing state with the vector commitment scheme in the store.
+// The state must be committed to the parameters of the scheme and the stored root commitment
+// must be the commitment of the root node. The state with hidden prefixes can only be opened
+// with the scheme which supports hiding commitments
+func OpenStateWithCommitment(vc VectorCommitment, store KVStore) (*State, error) {
+	if err := checkBatcher(store); err != nil {
+		return nil, err
+	}
+	ret := newState(vc, store)
+	rootBin, ok := ret.root.Get(nil)
+	if !ok {
+		return nil, xerrors.New("root commitment not found")
+	}
+	if err := ret.rootCommitmentCache.UnmarshalBinary(rootBin); err != nil {
+		return nil, xerrors.Errorf("wrong root commitment: %w", err)
+	}
+	ret.hidden.IterateKeys(nil, func(k []byte) bool {
+		ret.hiddenPrefixes = append(ret.hiddenPrefixes, k)
+		return true
+	})
+	if h, ok := vc.(HidingCommitment); len(ret.hiddenPrefixes) > 0 && (!ok || !h.SupportsHiding()) {
+		return nil, xerrors.New("state contains hidden nodes, but the vector commitment scheme does not support hiding commitments")
+	}
+	rootNodeBin, ok := ret.trie.Get(nil)
+	if !ok {
+		return nil, xerrors.New("root node not found")
+	}
+	rootNode, err := ret.NodeFromBytes(rootNodeBin)
+	if err != nil {
+		return nil, xerrors.Errorf("wrong root node: %w", err)
+	}
+	if !rootNode.Commit(vc).Equal(ret.rootCommitmentCache) {
+		return nil, xerrors.New("root commitment does not match the root node")
+	}
+	if !ret.Check(vc) {
+		return nil, xerrors.New("state is not committed to the parameters of the vector commitment scheme")
+	}
+	return ret, nil
+}
+
+func checkBatcher(store KVStore) error {
+	if _, ok := store.(Batcher); !ok {
+		return xerrors.New("store does not support atomic batches: wrap it with NewWALKVStore")
+	}
+	return nil
+}
+
+func newState(vc VectorCommitment, store KVStore) *State {
+	return &State{
+		vc:                  vc,
 		store:               store,
 		values:              store.Partition(prefixValues),
 		trie:                store.Partition(prefixTrie),
 		root:                store.Partition(prefixRootCommitment),
-		rootCommitmentCache: ts.Suite.G1().Point().Null(),
+		hidden:              store.Partition(prefixHidden),
+		rootCommitmentCache: vc.Suite().G1().Point().Null(),
 		nodeCache:           make(map[string]*Node),
 		valueCache:          make(map[string][]byte),
 	}
+}
+
+// init commits the empty state to the parameters of the vector commitment scheme
+func (st *State) init() {
 	// initially trie has null commitments at nil key
-	ret.trie.Set(nil, (&Node{}).Bytes())
+	st.StoreNode(nil, &Node{})
 
-	data := ts.Bytes()
-	ret.StoreValue(nil, data)
+	data := st.vc.Bytes()
+	st.StoreValue(nil, data)
 	hts := blake2b.Sum256(data)
-	commitTrustedSetup := ts.Suite.G1().Scalar().SetBytes(hts[:])
-	ret.updateKey(nil, 0, &ret.rootCommitmentCache, commitTrustedSetup)
+	commitTrustedSetup := st.vc.Suite().G1().Scalar().SetBytes(hts[:])
+	st.updateKey(nil, 0, &st.rootCommitmentCache, commitTrustedSetup)
 
-	ret.FlushCaches()
-	assert(ret.Check(ts), "consistency check failed")
-	return ret
+	st.FlushCaches()
+	assert(st.Check(st.vc), "consistency check failed")
 }
 
 func (st *State) NewNode(key []byte) (*Node, error) {
@@ -60,13 +158,15 @@ func (st *State) NewNode(key []byte) (*Node, error) {
 		return nil, xerrors.Errorf("node with the key '%s' already exists", string(key))
 	}
 	st.nodeCache[string(key)] = &Node{}
+	st.invalidateCachedProofs(key)
 	return st.nodeCache[string(key)], nil
 }
 
 func (st *State) GetValue(key []byte) ([]byte, bool) {
 	ret, ok := st.valueCache[string(key)]
 	if ok {
-		return ret, true
+		// nil in the cache marks deleted value
+		return ret, ret != nil
 	}
 	ret, ok = st.values.Get(key)
 	if !ok {
@@ -86,7 +186,8 @@ func (st *State) mustGetNode(key []byte) *Node {
 func (st *State) GetNode(key []byte) (*Node, bool) {
 	node, ok := st.nodeCache[string(key)]
 	if ok {
-		return node, true
+		// nil in the cache marks deleted node
+		return node, node != nil
 	}
 	nodeBin, ok := st.trie.Get(key)
 	if !ok {
@@ -107,25 +208,55 @@ func (st *State) StoreNode(key []byte, node *Node) {
 	st.nodeCache[string(key)] = node
 }
 
+// deleteValue marks value as deleted in the cache
+func (st *State) deleteValue(key []byte) {
+	st.valueCache[string(key)] = nil
+}
+
+// deleteNode marks node as deleted in the cache
+func (st *State) deleteNode(key []byte) {
+	st.nodeCache[string(key)] = nil
+	st.invalidateCachedProofs(key)
+}
+
+// FlushCaches writes all changes of the state to the store in one atomic batch
 func (st *State) FlushCaches() {
+	b := st.store.(Batcher).Batch()
+	values := b.Partition(prefixValues)
 	for k, v := range st.valueCache {
-		st.values.Set([]byte(k), v)
+		if v == nil {
+			values.Del([]byte(k))
+			continue
+		}
+		values.Set([]byte(k), v)
 	}
+	trie := b.Partition(prefixTrie)
 	for k, v := range st.nodeCache {
-		st.trie.Set([]byte(k), v.Bytes())
+		if v == nil {
+			trie.Del([]byte(k))
+			continue
+		}
+		trie.Set([]byte(k), v.Bytes())
+	}
+	hidden := b.Partition(prefixHidden)
+	for _, prefix := range st.hiddenCache {
+		hidden.Set(prefix, []byte{})
 	}
 	rootBin, err := st.rootCommitmentCache.MarshalBinary()
 	assert(err == nil, err)
+	b.Partition(prefixRootCommitment).Set(nil, rootBin)
 
-	st.root.Set(nil, rootBin)
+	err = b.Commit()
+	assert(err == nil, err)
 	st.valueCache = make(map[string][]byte)
 	st.nodeCache = make(map[string]*Node)
+	st.hiddenCache = nil
 }
 
 func (st *State) RootCommitment(ret ...kyber.Point) kyber.Point {
 	var ret1 kyber.Point
 	if len(ret) == 0 {
-		ret1 = st.ts.Suite.G1().Point()
+		ret1 = st.vc.Suite().G1().Point()
 	} else {
 		ret1 = ret[0]
 	}
@@ -142,8 +273,12 @@ func (st *State) UpdateStr(key, value string) {
 }
 
 func (st *State) Update(key, value []byte) {
+	if value == nil {
+		// nil in the value cache means deleted value
+		value = []byte{}
+	}
 	st.StoreValue(key, value)
-	vCommit := st.ts.Suite.G1().Scalar()
+	vCommit := st.vc.Suite().G1().Scalar()
 	scalarFromBytes(vCommit, value)
 	st.updateKey(key, 0, &st.rootCommitmentCache, vCommit)
 }
@@ -164,7 +299,9 @@ func (st *State) updateKey(path []byte, pathPosition int, updateCommitment *kybe
 		assert(err == nil, err)
 
 		node.pathFragment = path[pathPosition:]
-		st.updateTerminalValue(node, updateCommitment, valueCommitment)
+		node.terminalValue = valueCommitment
+		st.reblind(key, node)
+		*updateCommitment = node.Commit(st.vc)
 		return
 	}
 	// node for the path[:pathPosition] exists
@@ -182,7 +319,7 @@ func (st *State) updateKey(path []byte, pathPosition int, updateCommitment *kybe
 		// pathFragment is part of the path. No need for a fork, continue the path
 		if nextPathPosition == len(path) {
 			// reached the terminal value on this node
-			st.updateTerminalValue(node, updateCommitment, valueCommitment)
+			st.updateTerminalValue(key, node, updateCommitment, valueCommitment)
 		} else {
 			assert(nextPathPosition < len(path), "nextPathPosition < len(path)")
 			// didn't reach the end of the path
@@ -191,12 +328,12 @@ func (st *State) updateKey(path []byte, pathPosition int, updateCommitment *kybe
 
 			var oldCommitment kyber.Point
 			if node.children[childIndex] != nil {
-				oldCommitment = st.ts.Suite.G1().Point()
+				oldCommitment = st.vc.Suite().G1().Point()
 				oldCommitment.Set(node.children[childIndex])
 			}
 			// recursively update the rest of the path
 			st.updateKey(path, nextPathPosition+1, &node.children[childIndex], valueCommitment)
-			st.updateCommitment(updateCommitment, childIndex, oldCommitment, node.children[childIndex])
+			st.updateCommitment(key, node, updateCommitment, childIndex, oldCommitment, node.children[childIndex])
 		}
 		return
 	}
@@ -214,16 +351,18 @@ func (st *State) updateKey(path []byte, pathPosition int, updateCommitment *kybe
 	nodeContinue.pathFragment = node.pathFragment[len(prefix)+1:]
 	nodeContinue.children = node.children
 	nodeContinue.terminalValue = node.terminalValue
+	st.reblind(keyContinue, nodeContinue)
 
 	// adjust the old node. It will hold 2 commitments to the forked nodes
 	childIndexContinue := keyContinue[len(keyContinue)-1]
 	node.pathFragment = prefix
 	node.children = [256]kyber.Point{}
 	node.terminalValue = nil
+	st.reblind(key, node)
+	st.invalidateCachedProofs(key)
 
-	// previous commitment must exist
-	assert(*updateCommitment != nil, "*updateCommitment != nil")
-	node.children[childIndexContinue] = (*updateCommitment).Clone()
+	// path fragment of the continued node has changed, so its commitment must be recalculated
+	node.children[childIndexContinue] = nodeContinue.Commit(st.vc)
 
 	if pathPosition+len(prefix) == len(path) {
 		// no need for the new node
@@ -236,16 +375,109 @@ func (st *State) updateKey(path []byte, pathPosition int, updateCommitment *kybe
 		assert(err == nil, err)
 		nodeFork.pathFragment = path[len(keyFork):]
 		nodeFork.terminalValue = valueCommitment
+		st.reblind(keyFork, nodeFork)
 		childForkIndex := keyFork[len(keyFork)-1]
-		node.children[childForkIndex] = nodeFork.Commit(st.ts)
+		node.children[childForkIndex] = nodeFork.Commit(st.vc)
+	}
+	*updateCommitment = node.Commit(st.vc)
+}
+
+// DeleteStr for testing
+func (st *State) DeleteStr(key string) bool {
+	return st.Delete([]byte(key))
+}
+
+// Delete removes the key/value pair from the state and updates the trie.
+// Nodes which are left with only one child and no terminal value are merged with the child,
+// nodes left without children and terminal value are removed. This way the trie and its root commitment
+// after deletion are the same as if the key never was in the state.
+// The nil key contains the trusted setup and cannot be deleted.
+// Returns false if the key is not present in the state
+func (st *State) Delete(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	if _, ok := st.GetValue(key); !ok {
+		return false
+	}
+	st.deleteValue(key)
+	st.deleteKey(key, 0, &st.rootCommitmentCache)
+	return true
+}
+
+// deleteKey removes terminal value of the key from the trie. The key must be present in the trie
+func (st *State) deleteKey(path []byte, pathPosition int, updateCommitment *kyber.Point) {
+	assert(pathPosition <= len(path), "pathPosition <= len(path)")
+	key := path[:pathPosition]
+	node, ok := st.GetNode(key)
+	assert(ok, fmt.Sprintf("can't get node for key '%s'", string(key)))
+
+	nextPathPosition := pathPosition + len(node.pathFragment)
+	assert(nextPathPosition <= len(path), "nextPathPosition <= len(path)")
+	assert(bytes.Equal(node.pathFragment, path[pathPosition:nextPathPosition]), "pathFragment is not part of the path")
+
+	if nextPathPosition == len(path) {
+		// reached the terminal value on this node
+		assert(node.terminalValue != nil, "node.terminalValue != nil")
+		st.updateTerminalValue(key, node, updateCommitment, nil)
+	} else {
+		childIndex := path[nextPathPosition]
+		assert(node.children[childIndex] != nil, "node.children[childIndex] != nil")
+		oldCommitment := node.children[childIndex].Clone()
+		// recursively delete the rest of the path
+		st.deleteKey(path, nextPathPosition+1, &node.children[childIndex])
+		st.updateCommitment(key, node, updateCommitment, childIndex, oldCommitment, node.children[childIndex])
+	}
+	st.collapseNode(key, node, updateCommitment)
+}
+
+// collapseNode brings the node to the canonical form after deletion:
+// - the node without terminal value and without children is removed
+// - the node without terminal value and with only one child is merged with the child
+// The root node is never collapsed
+func (st *State) collapseNode(key []byte, node *Node, updateCommitment *kyber.Point) {
+	if len(key) == 0 || node.terminalValue != nil {
+		return
+	}
+	numChildren := 0
+	var childIndex int
+	for i, c := range node.children {
+		if c != nil {
+			numChildren++
+			childIndex = i
+		}
+	}
+	switch numChildren {
+	case 0:
+		st.deleteNode(key)
+		*updateCommitment = nil
+	case 1:
+		childKey := make([]byte, 0, len(key)+len(node.pathFragment)+1)
+		childKey = append(childKey, key...)
+		childKey = append(childKey, node.pathFragment...)
+		childKey = append(childKey, byte(childIndex))
+		child, ok := st.GetNode(childKey)
+		assert(ok, fmt.Sprintf("can't get node for key '%s'", string(childKey)))
+
+		pathFragment := make([]byte, 0, len(node.pathFragment)+1+len(child.pathFragment))
+		pathFragment = append(pathFragment, node.pathFragment...)
+		pathFragment = append(pathFragment, byte(childIndex))
+		pathFragment = append(pathFragment, child.pathFragment...)
+
+		node.pathFragment = pathFragment
+		node.children = child.children
+		node.terminalValue = child.terminalValue
+		st.deleteNode(childKey)
+		st.reblind(key, node)
+		st.invalidateCachedProofs(key)
+		*updateCommitment = node.Commit(st.vc)
 	}
-	*updateCommitment = node.Commit(st.ts)
 }
 
 // updateTerminalValue updates terminal value of the node
 // Returns delta for the upstream commitments
-func (st *State) updateTerminalValue(n *Node, updateCommitment *kyber.Point, valueCommitment kyber.Scalar) {
-	delta := st.ts.Suite.G1().Scalar()
+func (st *State) updateTerminalValue(key []byte, n *Node, updateCommitment *kyber.Point, valueCommitment kyber.Scalar) {
+	delta := st.vc.Suite().G1().Scalar()
 	if n.terminalValue != nil {
 		// already has terminal value
 		if valueCommitment == nil {
@@ -261,7 +493,12 @@ func (st *State) updateTerminalValue(n *Node, updateCommitment *kyber.Point, val
 		}
 	}
 	n.terminalValue = valueCommitment
-	deltaP := st.ts.Suite.G1().Point().Mul(delta, st.ts.LagrangeBasis[256])
+	deltaP := st.vc.CommitDelta(256, delta)
+	if deltaBlinding := st.rerandomize(key, n); deltaBlinding != nil {
+		deltaP.Add(deltaP, deltaBlinding)
+	} else {
+		st.updateCachedProofs(key, 256, delta)
+	}
 	if *updateCommitment == nil {
 		*updateCommitment = deltaP
 	} else {
@@ -269,12 +506,16 @@ func (st *State) updateTerminalValue(n *Node, updateCommitment *kyber.Point, val
 	}
 }
 
-func (st *State) updateCommitment(updateCommitment *kyber.Point, childIndex byte, oldC, newC kyber.Point) {
-	deltaScalar := scalarFromPoint(st.ts.Suite.G1().Scalar(), oldC)
-	newScalar := scalarFromPoint(st.ts.Suite.G1().Scalar(), newC)
+func (st *State) updateCommitment(key []byte, node *Node, updateCommitment *kyber.Point, childIndex byte, oldC, newC kyber.Point) {
+	deltaScalar := scalarFromPoint(st.vc.Suite().G1().Scalar(), oldC)
+	newScalar := scalarFromPoint(st.vc.Suite().G1().Scalar(), newC)
 	deltaScalar.Sub(newScalar, deltaScalar)
-	deltaP := st.ts.Suite.G1().Point()
-	deltaP.Mul(deltaScalar, st.ts.LagrangeBasis[childIndex])
+	deltaP := st.vc.CommitDelta(int(childIndex), deltaScalar)
+	if deltaBlinding := st.rerandomize(key, node); deltaBlinding != nil {
+		deltaP.Add(deltaP, deltaBlinding)
+	} else {
+		st.updateCachedProofs(key, int(childIndex), deltaScalar)
+	}
 	if *updateCommitment == nil {
 		*updateCommitment = deltaP
 	} else {
@@ -282,30 +523,55 @@ func (st *State) updateCommitment(updateCommitment *kyber.Point, childIndex byte
 	}
 }
 
-// Check checks consistency with the provided trusted setup
-// The trie always has to contain proof of binary data of the trusted setup present at nil key
-func (st *State) Check(ts *kzg.TrustedSetup) bool {
+// Check checks consistency with the provided vector commitment scheme
+// The trie always has to contain proof of parameters of the scheme (the trusted setup for KZG) present at nil key
+func (st *State) Check(vc VectorCommitment) bool {
 	v, ok := st.GetValue(nil)
 	if !ok {
 		return false
 	}
-	if !bytes.Equal(ts.Bytes(), v) {
+	if !bytes.Equal(vc.Bytes(), v) {
 		return false
 	}
-	if !bytes.Equal(st.ts.Bytes(), v) {
+	if !bytes.Equal(st.vc.Bytes(), v) {
 		return false
 	}
 	rootProof, ok := st.Prove(nil)
 	if !ok {
 		return false
 	}
-	return VerifyProof(ts, rootProof) == nil
+	return VerifyProof(vc.Verifier(), rootProof) == nil
 }
 
+// VectorCommitment returns the vector commitment scheme of the state
+func (st *State) VectorCommitment() VectorCommitment {
+	return st.vc
+}
+
+// StringTrie returns all nodes of the trie in the order of keys, including changes not flushed yet
 func (st *State) StringTrie() string {
-	ret := fmt.Sprintf("root commitment: %s\n", st.RootCommitment())
-	for _, k := range st.trie.Keys() {
-		ret += fmt.Sprintf("'%s':\n%s\n", k, st.mustGetNode([]byte(k)).String())
+	nodes := make(map[string]*Node)
+	keys := make([]string, 0)
+	st.trie.Iterate(nil, func(k, v []byte) bool {
+		if _, ok := st.nodeCache[string(k)]; ok {
+			return true
+		}
+		node, err := st.NodeFromBytes(v)
+		assert(err == nil, err)
+		nodes[string(k)] = node
+		keys = append(keys, string(k))
+		return true
+	})
+	for k, node := range st.nodeCache {
+		if node != nil {
+			nodes[k] = node
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	ret := fmt.Sprintf("root commitment: %s\n", st.rootCommitmentCache)
+	for _, k := range keys {
+		ret += fmt.Sprintf("'%s':\n%s\n", k, nodes[k].String())
 	}
 	return ret
 }
@@ -323,7 +589,7 @@ func GetStatsKVStore(kvs KVStore) *StatsKVStore {
 	maxLen := 0
 	sumKeyLen := 0
 	sumValueLen := 0
-	for _, k := range kvs.Keys() {
+	kvs.Iterate(nil, func(k, value []byte) bool {
 		ret.NumKeys++
 		sumKeyLen += len(k)
 		n := kl[len(k)]
@@ -331,12 +597,9 @@ func GetStatsKVStore(kvs KVStore) *StatsKVStore {
 		if len(k) > maxLen {
 			maxLen = len(k)
 		}
-		value, ok := kvs.Get([]byte(k))
-		if !ok {
-			panic("key not found")
-		}
 		sumValueLen += len(value)
-	}
+		return true
+	})
 	ret.KeyLen = make([]int, maxLen+1)
 	for l, n := range kl {
 		ret.KeyLen[l] = n
@@ -357,9 +620,9 @@ func GetStatsTrie(st *State) *StatsTrie {
 	ret := &StatsTrie{}
 	sumChildren := 0
 
-	for _, k := range st.trie.Keys() {
+	st.trie.IterateKeys(nil, func(k []byte) bool {
 		ret.NumNodes++
-		node, ok := st.GetNode([]byte(k))
+		node, ok := st.GetNode(k)
 		if !ok {
 			panic("can't get node")
 		}
@@ -377,7 +640,8 @@ func GetStatsTrie(st *State) *StatsTrie {
 		}
 		ret.NumChildren[numCh]++
 		sumChildren += numCh
-	}
+		return true
+	})
 	ret.AvgNumChildren = float64(sumChildren) / float64(ret.NumNodes)
 	return ret
 }
@@ -37,12 +37,13 @@ func BenchmarkProveVerify(b *testing.B) {
 	c := UpdateKeys(st, kpairs)
 	b.Logf("C = %s", c)
 
+	vk := st.vc.Verifier()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		idx := rand.Intn(len(kpairs))
 		proof, _ := st.ProveStr(kpairs[idx].key)
 		//b.Logf("proof len: %d, key len: %d", len(proof.Path), len(proof.Key))
-		err := VerifyProof(st.ts, proof)
+		err := VerifyProof(vk, proof)
 		if err != nil {
 			panic(err)
 		}
@@ -95,13 +96,80 @@ func BenchmarkVerify(b *testing.B) {
 		}
 
 		b.Logf("b.N = %d", b.N)
+		vk := st.vc.Verifier()
 		b.ResetTimer()
 
 		for i := 0; i < b.N; i++ {
-			err := VerifyProof(st.ts, proofs[i])
+			err := VerifyProof(vk, proofs[i])
 			if err != nil {
 				panic(err)
 			}
 		}
 	})
 }
+
+func BenchmarkVerifyAggregated(b *testing.B) {
+	suite := bn256.NewSuite()
+	ts, _ := kzg.TrustedSetupFromFile(suite, "example.setup")
+	rand.Seed(time.Now().UnixNano())
+	const numKeys = 100000
+
+	st := NewState(ts)
+
+	kpairs := GenKeys(numKeys)
+	b.Logf("num key/value pairs: %d", len(kpairs))
+	c := UpdateKeys(st, kpairs)
+	b.Logf("C = %s", c)
+
+	b.Run("1", func(b *testing.B) {
+		b.Logf("generating b.N = %d proofs", b.N)
+		proofs := make([]*Proof, b.N)
+		for i := 0; i < b.N; i++ {
+			idx := rand.Intn(len(kpairs))
+			proofs[i], _ = st.ProveAggregated([]byte(kpairs[idx].key))
+		}
+
+		b.Logf("b.N = %d", b.N)
+		vk := st.vc.Verifier()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			err := VerifyProof(vk, proofs[i])
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+func BenchmarkVerifyProofs(b *testing.B) {
+	suite := bn256.NewSuite()
+	ts, _ := kzg.TrustedSetupFromFile(suite, "example.setup")
+	rand.Seed(time.Now().UnixNano())
+	const numKeys = 100000
+
+	st := NewState(ts)
+
+	kpairs := GenKeys(numKeys)
+	b.Logf("num key/value pairs: %d", len(kpairs))
+	c := UpdateKeys(st, kpairs)
+	b.Logf("C = %s", c)
+
+	b.Run("1", func(b *testing.B) {
+		b.Logf("generating b.N = %d proofs", b.N)
+		proofs := make([]*Proof, b.N)
+		for i := 0; i < b.N; i++ {
+			idx := rand.Intn(len(kpairs))
+			proofs[i], _ = st.ProveStr(kpairs[idx].key)
+		}
+
+		b.Logf("b.N = %d", b.N)
+		vk := st.vc.Verifier()
+		b.ResetTimer()
+
+		err := VerifyProofs(vk, proofs...)
+		if err != nil {
+			panic(err)
+		}
+	})
+}
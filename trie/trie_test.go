@@ -1,10 +1,15 @@
 package trie
 
 import (
+	"bytes"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lunfardo314/verkle/ipa"
 	"github.com/lunfardo314/verkle/kzg"
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/kyber/v3"
@@ -17,7 +22,7 @@ func TestState0(t *testing.T) {
 	require.NoError(t, err)
 	st := NewState(ts)
 
-	require.True(t, st.Check(ts))
+	require.True(t, st.Check(NewKZG(ts)))
 
 	proof, ok := st.Prove(nil)
 	require.True(t, ok)
@@ -31,7 +36,7 @@ func TestState0(t *testing.T) {
 	require.EqualValues(t, ts.Bytes(), proof.Value)
 	require.EqualValues(t, 1, len(proof.Path))
 
-	err = VerifyProof(st.ts, proof)
+	err = VerifyProof(st.vc.Verifier(), proof)
 	require.NoError(t, err)
 	t.Logf("\nTRIE: \n%s\n", st.StringTrie())
 }
@@ -42,6 +47,16 @@ func TestString(t *testing.T) {
 	require.NoError(t, err)
 	st := NewState(ts)
 	t.Logf("\nTRIE: \n%s\n", st.StringTrie())
+
+	// not flushed changes are visible
+	st.UpdateStr("abc", "1")
+	st.UpdateStr("abd", "2")
+	s := st.StringTrie()
+	require.Contains(t, s, "'a':")
+	st.FlushCaches()
+	require.EqualValues(t, s, st.StringTrie())
+	st.DeleteStr("abd")
+	require.Contains(t, st.StringTrie(), "pathFragment: 'bc'")
 }
 
 //nolint:funlen
@@ -52,7 +67,7 @@ func TestTrie1(t *testing.T) {
 
 	t.Run("1", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		st.UpdateStr("a", "b")
 		st.FlushCaches()
@@ -68,13 +83,13 @@ func TestTrie1(t *testing.T) {
 		require.EqualValues(t, "a", string(proofa.Key))
 		require.EqualValues(t, "b", string(proofa.Value))
 
-		err = VerifyProof(ts, proofa)
+		err = VerifyProof(NewKZGVerifier(ts.VerifierKey()), proofa)
 		require.NoError(t, err)
 		t.Logf("\nTRIE: \n%s\n", st.StringTrie())
 	})
 	t.Run("2", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		st.UpdateStr("a", "b")
 		st.UpdateStr("ab", "bc")
@@ -93,7 +108,7 @@ func TestTrie1(t *testing.T) {
 		require.EqualValues(t, "a", string(proofa.Key))
 		require.EqualValues(t, "b", string(proofa.Value))
 
-		err = VerifyProof(ts, proofa)
+		err = VerifyProof(NewKZGVerifier(ts.VerifierKey()), proofa)
 		require.NoError(t, err)
 
 		proofab, ok := st.ProveStr("ab")
@@ -107,7 +122,7 @@ func TestTrie1(t *testing.T) {
 		require.EqualValues(t, "ab", string(proofab.Key))
 		require.EqualValues(t, "bc", string(proofab.Value))
 
-		err = VerifyProof(ts, proofab)
+		err = VerifyProof(NewKZGVerifier(ts.VerifierKey()), proofab)
 		require.NoError(t, err)
 
 		proofzz, ok := st.ProveStr("abrakadabra")
@@ -121,20 +136,20 @@ func TestTrie1(t *testing.T) {
 		require.EqualValues(t, "abrakadabra", string(proofzz.Key))
 		require.EqualValues(t, "zzzz", string(proofzz.Value))
 
-		err = VerifyProof(ts, proofzz)
+		err = VerifyProof(NewKZGVerifier(ts.VerifierKey()), proofzz)
 		require.NoError(t, err)
 
 		t.Logf("\nTRIE: \n%s\n", st.StringTrie())
 	})
 	t.Run("3", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 		UpdateKeys(st, kvpairs1)
 		t.Logf("\nTRIE: \n%s\n", st.StringTrie())
 	})
 	t.Run("determinism 1", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		c1 := UpdateKeys(st, kvpairs1)
 		t.Logf("C1 = %s", c1.String())
@@ -144,12 +159,12 @@ func TestTrie1(t *testing.T) {
 	})
 	t.Run("determinism 2", func(t *testing.T) {
 		st1 := NewState(ts)
-		require.True(t, st1.Check(ts))
+		require.True(t, st1.Check(NewKZG(ts)))
 		c1 := UpdateKeys(st1, kvpairs1)
 		t.Logf("C1 = %s", c1.String())
 
 		st2 := NewState(ts)
-		require.True(t, st2.Check(ts))
+		require.True(t, st2.Check(NewKZG(ts)))
 
 		c2 := UpdateKeys(st2, kvpairs1)
 		t.Logf("C1 = %s", c2.String())
@@ -158,12 +173,12 @@ func TestTrie1(t *testing.T) {
 	})
 	t.Run("determinism 3", func(t *testing.T) {
 		st1 := NewState(ts)
-		require.True(t, st1.Check(ts))
+		require.True(t, st1.Check(NewKZG(ts)))
 		c1 := UpdateKeys(st1, kvpairs1)
 		t.Logf("C1 = %s", c1.String())
 
 		st2 := NewState(ts)
-		require.True(t, st2.Check(ts))
+		require.True(t, st2.Check(NewKZG(ts)))
 
 		rpairs := RandomizeKeys(kvpairs1)
 		c2 := UpdateKeys(st2, rpairs)
@@ -175,7 +190,7 @@ func TestTrie1(t *testing.T) {
 		var prev kyber.Point
 		for i := 0; i < 20; i++ {
 			st := NewState(ts)
-			require.True(t, st.Check(ts))
+			require.True(t, st.Check(NewKZG(ts)))
 
 			rpairs := RandomizeKeys(kvpairs1)
 			c := UpdateKeys(st, rpairs)
@@ -189,7 +204,7 @@ func TestTrie1(t *testing.T) {
 	})
 	t.Run("example", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		st.UpdateStr("abra", "something")
 		st.UpdateStr("abrakadabra", "anything")
@@ -209,20 +224,20 @@ func TestTrie2(t *testing.T) {
 
 	t.Run("1", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 		UpdateKeys(st, kvpairs1)
 		t.Logf("\nTRIE: \n%s\n", st.StringTrie())
 
 		for _, kv := range kvpairs1 {
 			proof, ok := st.ProveStr(kv.key)
 			require.True(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 		}
 	})
 	t.Run("2", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		for _, kv := range kvpairs1 {
 			st.UpdateStr(kv.key, kv.value)
@@ -230,7 +245,7 @@ func TestTrie2(t *testing.T) {
 
 			proof, ok := st.ProveStr(kv.key)
 			require.True(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 		}
 		t.Logf("\nTRIE: \n%s\n", st.StringTrie())
@@ -246,7 +261,7 @@ func TestTrie3(t *testing.T) {
 		const num = 30
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeys(num)
 		for i, kp := range kpairs {
@@ -259,7 +274,7 @@ func TestTrie3(t *testing.T) {
 		const num = 30
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeys(num)
 		for i, kp := range kpairs {
@@ -270,7 +285,7 @@ func TestTrie3(t *testing.T) {
 			proof, ok := st.ProveStr(kv.key)
 			t.Logf("proof len: %d, key: %s, val: %s", len(proof.Path), string(proof.Key), string(proof.Value))
 			require.True(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 		}
 	})
@@ -287,7 +302,7 @@ func TestTrieProveVerify(t *testing.T) {
 		const numChecks = 100
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeys(numKeys)
 		t.Logf("num key/value pairs: %d", len(kpairs))
@@ -301,7 +316,7 @@ func TestTrieProveVerify(t *testing.T) {
 			proof, ok := st.ProveStr(kpairs[idx].key)
 			t.Logf("proof len: %d, key len: %d", len(proof.Path), len(proof.Key))
 			require.True(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 
 		}
@@ -312,7 +327,7 @@ func TestTrieProveVerify(t *testing.T) {
 		const numChecks = 100
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeysISCP(numKeys, numSC)
 		t.Logf("num key/value pairs: %d", len(kpairs))
@@ -326,7 +341,7 @@ func TestTrieProveVerify(t *testing.T) {
 			proof, ok := st.ProveStr(kpairs[idx].key)
 			t.Logf("proof len: %d, key len: %d", len(proof.Path), len(proof.Key))
 			require.True(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 
 		}
@@ -344,7 +359,7 @@ func TestTrieDistributionProofLength(t *testing.T) {
 		const numChecks = 100
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeys(numKeys)
 		t.Logf("num key/value pairs: %d", len(kpairs))
@@ -368,7 +383,7 @@ func TestTrieDistributionProofLength(t *testing.T) {
 		const numChecks = 100
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeysISCP(numKeys, numSC)
 		t.Logf("num key/value pairs: %d", len(kpairs))
@@ -398,7 +413,7 @@ func TestTrieStats(t *testing.T) {
 		const numKeys = 100000
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeys(numKeys)
 		t.Logf("num key/value pairs: %d", len(kpairs))
@@ -434,7 +449,7 @@ func TestTrieStats(t *testing.T) {
 		const numSC = 300
 
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		kpairs := GenKeysISCP(numKeys, numSC)
 		t.Logf("num key/value pairs: %d", len(kpairs))
@@ -474,7 +489,7 @@ func TestProofAbsence(t *testing.T) {
 
 	t.Run("1", func(t *testing.T) {
 		st := NewState(ts)
-		require.True(t, st.Check(ts))
+		require.True(t, st.Check(NewKZG(ts)))
 
 		for _, kv := range kvpairs1 {
 			st.UpdateStr(kv.key, kv.value)
@@ -482,15 +497,539 @@ func TestProofAbsence(t *testing.T) {
 
 			proof, ok := st.ProveStr(kv.key)
 			require.True(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 		}
 		t.Logf("\nTRIE: \n%s\n", st.StringTrie())
 		for _, kv := range kvpairsNotInState {
 			proof, ok := st.ProveStr(kv.key)
 			require.False(t, ok)
-			err := VerifyProof(st.ts, proof)
+			err := VerifyProof(st.vc.Verifier(), proof)
 			require.NoError(t, err)
 		}
 	})
 }
+
+func requireSameTrie(t *testing.T, st1, st2 *State) {
+	require.True(t, st1.RootCommitment().Equal(st2.RootCommitment()))
+	keys1 := allKeys(st1.trie)
+	keys2 := allKeys(st2.trie)
+	require.EqualValues(t, keys1, keys2)
+	for _, k := range keys1 {
+		n1, _ := st1.trie.Get([]byte(k))
+		n2, _ := st2.trie.Get([]byte(k))
+		require.EqualValues(t, n1, n2)
+	}
+	require.EqualValues(t, allKeys(st1.values), allKeys(st2.values))
+}
+
+func TestDelete(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	t.Run("not present", func(t *testing.T) {
+		st := NewState(ts)
+		c := UpdateKeys(st, kvpairs1)
+		require.False(t, st.DeleteStr("abrak4"))
+		require.False(t, st.DeleteStr(""))
+		st.FlushCaches()
+		require.True(t, c.Equal(st.RootCommitment()))
+		require.True(t, st.Check(NewKZG(ts)))
+	})
+	t.Run("delete all", func(t *testing.T) {
+		st1 := NewState(ts)
+		UpdateKeys(st1, kvpairs1)
+		for _, kv := range RandomizeKeys(kvpairs1) {
+			st1.DeleteStr(kv.key)
+		}
+		st1.FlushCaches()
+		require.True(t, st1.Check(NewKZG(ts)))
+
+		st2 := NewState(ts)
+		requireSameTrie(t, st1, st2)
+	})
+	t.Run("delete some", func(t *testing.T) {
+		toDelete := map[string]bool{
+			"ab":           true,
+			"abrak3adabra": true,
+			"abrak3a":      true,
+			"abrak2":       true,
+			"a":            true,
+		}
+		remaining := make([]*kvpair, 0)
+		for _, kv := range kvpairs1 {
+			if !toDelete[kv.key] {
+				remaining = append(remaining, kv)
+			}
+		}
+		st1 := NewState(ts)
+		UpdateKeys(st1, kvpairs1)
+		for k := range toDelete {
+			require.True(t, st1.DeleteStr(k))
+		}
+		st1.FlushCaches()
+		require.True(t, st1.Check(NewKZG(ts)))
+
+		st2 := NewState(ts)
+		UpdateKeys(st2, RandomizeKeys(remaining))
+		requireSameTrie(t, st1, st2)
+
+		for k := range toDelete {
+			proof, ok := st1.ProveStr(k)
+			require.False(t, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+		for _, kv := range remaining {
+			proof, ok := st1.ProveStr(kv.key)
+			require.True(t, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+	})
+	t.Run("delete and update", func(t *testing.T) {
+		st1 := NewState(ts)
+		UpdateKeys(st1, kvpairs1)
+		st1.DeleteStr("abrak3ab")
+		st1.UpdateStr("abrak3ab", "11")
+		st1.DeleteStr("abrak3abc")
+		st1.FlushCaches()
+
+		st2 := NewState(ts)
+		UpdateKeys(st2, kvpairs1)
+		require.True(t, st2.DeleteStr("abrak3abc"))
+		st2.FlushCaches()
+		requireSameTrie(t, st1, st2)
+	})
+	t.Run("delete random", func(t *testing.T) {
+		kpairs := GenKeys(30)
+		st1 := NewState(ts)
+		UpdateKeys(st1, kpairs)
+		for _, kv := range kpairs[:15] {
+			require.True(t, st1.DeleteStr(kv.key))
+		}
+		st1.FlushCaches()
+
+		st2 := NewState(ts)
+		UpdateKeys(st2, kpairs[15:])
+		requireSameTrie(t, st1, st2)
+	})
+}
+
+func TestProofKeyBinding(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	st := NewState(ts)
+	UpdateKeys(st, kvpairs1)
+
+	t.Run("wrong key", func(t *testing.T) {
+		for _, kv := range kvpairs1 {
+			proof, ok := st.ProveStr(kv.key)
+			require.True(t, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+
+			proof.Key = []byte("x" + kv.key[1:])
+			require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+			proof.Key = []byte(kv.key + "x")
+			require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+	})
+	t.Run("wrong path fragment", func(t *testing.T) {
+		proof, ok := st.ProveStr("abrakadabra")
+		require.True(t, ok)
+		require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+
+		last := proof.Path[len(proof.Path)-1]
+		require.True(t, len(last.PathFragment) > 0)
+		fragment := make([]byte, len(last.PathFragment))
+		copy(fragment, last.PathFragment)
+		fragment[0]++
+		last.PathFragment = fragment
+		proof.Key = append(proof.Key[:len(proof.Key)-len(fragment)], fragment...)
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	})
+}
+
+func TestProofAbsenceDivergingFragment(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	st := NewState(ts)
+	UpdateKeys(st, kvpairs1)
+
+	t.Run("diverging keys", func(t *testing.T) {
+		for _, key := range []string{"abrakad", "abrakadabr", "abrakadabrX", "abrakaX", "abrak1", "abrak1adabrX", "abrak2ada", "abrak3adab"} {
+			proof, ok := st.ProveStr(key)
+			require.False(t, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+	})
+	t.Run("diverging proof for other key", func(t *testing.T) {
+		proof, ok := st.ProveStr("abrakadabrX")
+		require.False(t, ok)
+		require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+
+		proof.Key = []byte("abrakadabra")
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		proof.Key = []byte("abrakadabraX")
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	})
+	t.Run("wrong terminal commitment", func(t *testing.T) {
+		proof, ok := st.ProveStr("abrak3adab")
+		require.False(t, ok)
+		require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		require.NotNil(t, proof.TerminalCommitment)
+
+		proof.TerminalCommitment = nil
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	})
+	t.Run("fake path fragment", func(t *testing.T) {
+		proof, ok := st.ProveStr("abrakadabra")
+		require.True(t, ok)
+		last := proof.Path[len(proof.Path)-1]
+		last.PathFragment = []byte("X")
+		proof.Value = nil
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	})
+}
+
+func TestProofAggregated(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	st := NewState(ts)
+	UpdateKeys(st, kvpairs1)
+
+	t.Run("presence", func(t *testing.T) {
+		for _, kv := range kvpairs1 {
+			proof, ok := st.ProveAggregatedStr(kv.key)
+			require.True(t, ok)
+			require.NotNil(t, proof.Aggregated)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+	})
+	t.Run("absence", func(t *testing.T) {
+		for _, kv := range kvpairsNotInState {
+			proof, ok := st.ProveAggregatedStr(kv.key)
+			require.False(t, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+		proof, ok := st.ProveAggregatedStr("abrakad")
+		require.False(t, ok)
+		require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	})
+	t.Run("wrong value", func(t *testing.T) {
+		proof, ok := st.ProveAggregatedStr("abrak3abc")
+		require.True(t, ok)
+		proof.Value = []byte("13")
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	})
+	t.Run("wrong aggregated proof", func(t *testing.T) {
+		proof1, ok := st.ProveAggregatedStr("abrak3abc")
+		require.True(t, ok)
+		proof2, ok := st.ProveAggregatedStr("abrak3ab")
+		require.True(t, ok)
+		proof1.Aggregated = proof2.Aggregated
+		require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof1))
+	})
+}
+
+func TestVerifyProofs(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	st := NewState(ts)
+	UpdateKeys(st, kvpairs1)
+
+	proofs := make([]*Proof, 0)
+	for _, kv := range kvpairs1 {
+		proof, ok := st.ProveStr(kv.key)
+		require.True(t, ok)
+		proofs = append(proofs, proof)
+	}
+	for _, kv := range kvpairsNotInState[:5] {
+		proof, ok := st.ProveStr(kv.key)
+		require.False(t, ok)
+		proofs = append(proofs, proof)
+	}
+	proof, ok := st.ProveAggregatedStr("abrak2")
+	require.True(t, ok)
+	proofs = append(proofs, proof)
+
+	// the verifier needs only the verifier key
+	vk, err := kzg.VerifierKeyFromBytes(suite, ts.VerifierKey().Bytes())
+	require.NoError(t, err)
+	v := NewKZGVerifier(vk)
+
+	require.NoError(t, VerifyProofs(v, proofs...))
+	require.NoError(t, VerifyProofs(v))
+
+	proofs[3].Value = []byte("wrong")
+	require.Error(t, VerifyProofs(v, proofs...))
+	proofs[3].Value = []byte(kvpairs1[3].value)
+	require.NoError(t, VerifyProofs(v, proofs...))
+	proofs[4].Path[len(proofs[4].Path)-1].Proof = proofs[4].Path[0].Proof
+	require.Error(t, VerifyProofs(v, proofs...))
+}
+
+func TestProofCache(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+
+	st1 := NewState(ts)
+	st1.EnableProofCache(true)
+	UpdateKeys(st1, kvpairs1)
+	st2 := NewState(ts)
+	UpdateKeys(st2, kvpairs1)
+
+	keys := make([]string, 0)
+	for _, kv := range kvpairs1 {
+		keys = append(keys, kv.key)
+	}
+	for _, kv := range kvpairsNotInState {
+		keys = append(keys, kv.key)
+	}
+	requireSameProofs := func() {
+		for _, k := range keys {
+			proof1, ok1 := st1.ProveStr(k)
+			proof2, ok2 := st2.ProveStr(k)
+			require.EqualValues(t, ok2, ok1)
+			require.EqualValues(t, proof2.Len(), proof1.Len())
+			for i := range proof1.Path {
+				require.True(t, proof2.Path[i].Proof.(kyber.Point).Equal(proof1.Path[i].Proof.(kyber.Point)))
+			}
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof1))
+		}
+	}
+	// fill the cache
+	require.True(t, st1.CacheNodeProofs([]byte("abr")))
+	require.True(t, st1.CacheNodeProofs([]byte("abrak1")))
+	require.False(t, st1.CacheNodeProofs([]byte("abrak7")))
+	require.EqualValues(t, 257, len(st1.proofCache["abr"]))
+	requireSameProofs()
+
+	// the caller modifying the proof does not corrupt the cache
+	proof, _ := st1.ProveStr("abra")
+	for _, p := range proof.Path {
+		p.Proof.(kyber.Point).Null()
+	}
+	requireSameProofs()
+
+	for _, st := range []*State{st1, st2} {
+		st.UpdateStr("ab", "22")    // update terminal value
+		st.UpdateStr("abrak3x", "") // new node below the existing one
+		st.UpdateStr("abrak1", "1") // fork of the path fragment
+		st.UpdateStr("abc", "3")    // new child of the node with the terminal value
+		st.DeleteStr("abrak3abc")   // removed node
+		st.DeleteStr("abrak3")      // node collapsed with the child
+		st.FlushCaches()
+	}
+	keys = append(keys, "abrak3x", "abrak1", "abc")
+	requireSameProofs()
+
+	st1.EnableProofCache(false)
+	require.Nil(t, st1.proofCache)
+	requireSameProofs()
+}
+
+func TestHidePrefix(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromSeed(suite, 257, []byte("hiding test"))
+	require.NoError(t, err)
+
+	t.Run("not supported", func(t *testing.T) {
+		tsFile, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+		require.NoError(t, err)
+		require.Error(t, NewState(tsFile).HidePrefix([]byte("abrak3")))
+	})
+
+	st := NewState(ts)
+	st.EnableProofCache(true)
+	require.Error(t, st.HidePrefix(nil))
+	require.NoError(t, st.HidePrefix([]byte("abrak3")))
+	UpdateKeys(st, kvpairs1)
+	require.Error(t, st.HidePrefix([]byte("ab")))
+	require.True(t, st.Check(NewKZG(ts)))
+
+	hidden := []string{"abrak3", "abrak3a", "abrak3ab", "abrak3abc", "abrak3ad"}
+	for _, k := range hidden {
+		node, ok := st.GetNode([]byte(k))
+		require.True(t, ok)
+		require.NotNil(t, node.blindingSeed, k)
+	}
+	node, _ := st.GetNode([]byte("abr"))
+	require.Nil(t, node.blindingSeed)
+
+	stNotHidden := NewState(ts)
+	UpdateKeys(stNotHidden, kvpairs1)
+	require.False(t, stNotHidden.RootCommitment().Equal(st.RootCommitment()))
+
+	requireProofs := func() {
+		for _, kv := range kvpairs1 {
+			proof, ok := st.ProveStr(kv.key)
+			_, present := st.GetValue([]byte(kv.key))
+			require.EqualValues(t, present, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+			last := proof.Path[proof.Len()-1]
+			require.EqualValues(t, strings.HasPrefix(kv.key, "abrak3"), last.Blinding != nil)
+			require.Nil(t, proof.Path[0].Blinding)
+
+			// wrong blinding value
+			if last.Blinding != nil {
+				last.Blinding = suite.G1().Scalar().SetInt64(1)
+				require.Error(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+			}
+		}
+		for _, kv := range kvpairsNotInState {
+			proof, ok := st.ProveStr(kv.key)
+			require.False(t, ok)
+			require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+		}
+	}
+	requireProofs()
+
+	// each change of the hidden node replaces its blinding
+	node, _ = st.GetNode([]byte("abrak3ab"))
+	seed := node.blindingSeed
+	st.UpdateStr("abrak3ab", "111")
+	st.UpdateStr("abrak3x", "1")
+	require.True(t, st.DeleteStr("abrak3abc"))
+	st.FlushCaches()
+	node, _ = st.GetNode([]byte("abrak3ab"))
+	require.False(t, bytes.Equal(seed, node.blindingSeed))
+	require.True(t, st.Check(NewKZG(ts)))
+	requireProofs()
+
+	proof, ok := st.ProveAggregatedStr("abrak3ab")
+	require.True(t, ok)
+	require.Nil(t, proof.Aggregated)
+	require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	proof, ok = st.ProveAggregatedStr("abrakadabra")
+	require.True(t, ok)
+	require.NotNil(t, proof.Aggregated)
+	require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+}
+
+func TestOpenState(t *testing.T) {
+	suite := bn256.NewSuite()
+	ts, err := kzg.TrustedSetupFromSeed(suite, 257, []byte("hiding test"))
+	require.NoError(t, err)
+	fname := filepath.Join(t.TempDir(), "state.data")
+
+	store, err := OpenFileKVStore(fname)
+	require.NoError(t, err)
+	st, err := NewStateWithStore(ts, store)
+	require.NoError(t, err)
+	require.NoError(t, st.HidePrefix([]byte("abrak3")))
+	// the hidden prefix is written with other changes of the state
+	require.EqualValues(t, 0, store.Partition(prefixHidden).Size())
+	c := UpdateKeys(st, kvpairs1)
+	require.EqualValues(t, 1, store.Partition(prefixHidden).Size())
+	_, err = NewStateWithStore(ts, store)
+	require.Error(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = OpenFileKVStore(fname)
+	require.NoError(t, err)
+	st, err = OpenState(ts, store)
+	require.NoError(t, err)
+	require.True(t, c.Equal(st.RootCommitment()))
+	for _, kv := range kvpairs1 {
+		v, ok := st.GetValue([]byte(kv.key))
+		require.True(t, ok)
+		require.EqualValues(t, kv.value, string(v))
+		proof, ok := st.ProveStr(kv.key)
+		require.True(t, ok)
+		require.NoError(t, VerifyProof(NewKZGVerifier(ts.VerifierKey()), proof))
+	}
+	// hidden prefixes are loaded
+	st.UpdateStr("abrak3x", "1")
+	st.FlushCaches()
+	node, ok := st.GetNode([]byte("abrak3x"))
+	require.True(t, ok)
+	require.NotNil(t, node.blindingSeed)
+	require.True(t, st.Check(NewKZG(ts)))
+
+	// other scheme
+	tsFile, err := kzg.TrustedSetupFromFile(suite, "example.setup")
+	require.NoError(t, err)
+	_, err = OpenState(tsFile, store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "hiding")
+	params, err := ipa.NewParams(suite, 257)
+	require.NoError(t, err)
+	_, err = OpenStateWithCommitment(NewIPA(params), store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "hiding")
+
+	// stored root does not match the root node
+	rootBin, _ := st.root.Get(nil)
+	otherRoot, err := NewState(ts).RootCommitment().MarshalBinary()
+	require.NoError(t, err)
+	st.root.Set(nil, otherRoot)
+	_, err = OpenState(ts, store)
+	require.Error(t, err)
+	st.root.Set(nil, rootBin)
+	_, err = OpenState(ts, store)
+	require.NoError(t, err)
+
+	_, err = OpenState(ts, NewSimpleKVStore())
+	require.Error(t, err)
+	require.NoError(t, store.Close())
+}
+
+func TestFlushCachesAtomic(t *testing.T) {
+	ts, err := kzg.TrustedSetupFromFile(bn256.NewSuite(), "example.setup")
+	require.NoError(t, err)
+	fname := filepath.Join(t.TempDir(), "state.data")
+
+	store, err := OpenFileKVStore(fname)
+	require.NoError(t, err)
+	st, err := NewStateWithStore(ts, store)
+	require.NoError(t, err)
+	c := UpdateKeys(st, kvpairs1)
+	fi, err := os.Stat(fname)
+	require.NoError(t, err)
+	sizeBefore := fi.Size()
+	st.UpdateStr("abrak2", "new value")
+	st.UpdateStr("abrak3x", "1")
+	require.True(t, st.DeleteStr("abrak3a"))
+	st.FlushCaches()
+	require.False(t, c.Equal(st.RootCommitment()))
+	require.NoError(t, store.Close())
+
+	// crash in the middle of the last flush leaves the previous state
+	require.NoError(t, os.Truncate(fname, sizeBefore+10))
+	store, err = OpenFileKVStore(fname)
+	require.NoError(t, err)
+	st, err = OpenState(ts, store)
+	require.NoError(t, err)
+	require.True(t, c.Equal(st.RootCommitment()))
+	v, ok := st.GetValue([]byte("abrak3a"))
+	require.True(t, ok)
+	require.EqualValues(t, "10", string(v))
+	require.NoError(t, store.Close())
+
+	t.Run("wal", func(t *testing.T) {
+		store := &kvStoreNoBatch{NewSimpleKVStore()}
+		wal, err := NewWALKVStore(store, filepath.Join(t.TempDir(), "wal"))
+		require.NoError(t, err)
+		st, err := NewStateWithStore(ts, wal)
+		require.NoError(t, err)
+		c := UpdateKeys(st, kvpairs1)
+		st, err = OpenState(ts, wal)
+		require.NoError(t, err)
+		require.True(t, c.Equal(st.RootCommitment()))
+		require.NoError(t, wal.Close())
+
+		_, err = OpenState(ts, store)
+		require.Error(t, err)
+		_, err = NewStateWithStore(ts, &kvStoreNoBatch{NewSimpleKVStore()})
+		require.Error(t, err)
+	})
+}
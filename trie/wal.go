@@ -0,0 +1,112 @@
+package trie
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+// WALKVStore makes batches atomic for the key/value store which does not support them, with the write-ahead log.
+// Commit writes the batch to the log file and syncs it before the batch is applied to the store.
+// The log is cleared after the store is flushed, so the store must implement Flusher. Upon opening, the complete batch
+// left in the log by the crash is applied to the store again, the incomplete one is discarded.
+// Keys and values of batches are limited as in FileKVStore. Set and Del of the store are not logged
+type WALKVStore struct {
+	KVStore
+	file *os.File
+}
+
+// NewWALKVStore wraps the store with the write-ahead log in the file. The file is created if it does not exist.
+// The store must implement Flusher: the log is cleared only when the applied batch is durable in the store
+func NewWALKVStore(store KVStore, fname string) (*WALKVStore, error) {
+	if _, ok := store.(Flusher); !ok {
+		return nil, xerrors.New("store does not implement Flusher")
+	}
+	file, err := os.OpenFile(fname, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err = syncDir(filepath.Dir(fname)); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	ret := &WALKVStore{
+		KVStore: store,
+		file:    file,
+	}
+	if err = ret.recover(); err != nil {
+		_ = file.Close()
+		return nil, xerrors.Errorf("recovering from write-ahead log '%s': %w", fname, err)
+	}
+	return ret, nil
+}
+
+// recover applies the batch from the log, if it is complete
+func (w *WALKVStore) recover() error {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ops, _, err := readFileKVRecord(bufio.NewReader(w.file))
+	if err == io.EOF || err == io.ErrUnexpectedEOF || xerrors.Is(err, errCorruptedRecord) {
+		// nothing or incomplete batch in the log
+		return w.clear()
+	}
+	if err != nil {
+		return err
+	}
+	batchOps := make([]batchOp, len(ops))
+	for i, op := range ops {
+		batchOps[i] = batchOp{
+			del:   op.op == fileKVDel,
+			key:   []byte(op.key),
+			value: op.value,
+		}
+	}
+	return w.apply(batchOps)
+}
+
+// apply writes operations to the store, flushes it and clears the log
+func (w *WALKVStore) apply(ops []batchOp) error {
+	applyBatch(w.KVStore, ops)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return w.clear()
+}
+
+func (w *WALKVStore) clear() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *WALKVStore) Batch() Batch {
+	return &batch{commit: func(ops []batchOp) error {
+		// the batch which can't be read back from the log is not written
+		if err := checkFileKVLimits(ops); err != nil {
+			return err
+		}
+		record, _, _ := encodeFileKVRecord(ops)
+		if _, err := w.file.WriteAt(record, 0); err != nil {
+			return err
+		}
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+		return w.apply(ops)
+	}}
+}
+
+// Flush flushes the store
+func (w *WALKVStore) Flush() error {
+	return w.KVStore.(Flusher).Flush()
+}
+
+// Close closes the log file. The store is not closed
+func (w *WALKVStore) Close() error {
+	return w.file.Close()
+}